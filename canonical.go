@@ -0,0 +1,59 @@
+package form
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Canonical causes the Encoder to produce canonical output, as needed for
+// signing requests, e.g. OAuth 1.0a signature base strings or canonical
+// query strings. In canonical mode the keys and values are escaped as
+// specified by RFC 3986, i.e. spaces are escaped as "%20" instead of "+",
+// and the pairs are sorted by their escaped keys and then by their escaped
+// values, in byte order.
+func (e *Encoder) Canonical() *Encoder {
+	e.canonical = true
+	return e
+}
+
+// A pair holds the position of an escaped key-value pair in an Encoder's
+// buffer. The key is at buf[start:eq] and the value at buf[eq+1:end].
+type pair struct {
+	start, eq, end int
+}
+
+// addPair writes the escaped key-value pair to the Encoder's
+// buffer, without a separator, and records its position.
+func (e *Encoder) addPair(key, val string) {
+	p := pair{start: e.buf.Len()}
+	writeQueryEscaped(e.buf, key, true)
+	p.eq = e.buf.Len()
+	e.buf.WriteByte('=')
+	writeQueryEscaped(e.buf, val, true)
+	p.end = e.buf.Len()
+	e.pairs = append(e.pairs, p)
+}
+
+// sortPairs replaces the contents of the Encoder's buffer with
+// its pairs, sorted by key and value and separated by "&".
+func (e *Encoder) sortPairs() {
+	b := e.buf.Bytes()
+	sort.Slice(e.pairs, func(i, j int) bool {
+		pi, pj := e.pairs[i], e.pairs[j]
+		if c := bytes.Compare(b[pi.start:pi.eq], b[pj.start:pj.eq]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(b[pi.eq+1:pi.end], b[pj.eq+1:pj.end]) < 0
+	})
+
+	buf := getBuffer()
+	for i, p := range e.pairs {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+		buf.Write(b[p.start:p.end])
+	}
+	e.pairs = e.pairs[:0]
+	e.releaseBuffer()
+	e.buf = buf
+}
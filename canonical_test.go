@@ -0,0 +1,87 @@
+package form
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncoderCanonical(t *testing.T) {
+	type params struct {
+		Z    string    `form:"z"`
+		A    []string  `form:"a"`
+		Text string    `form:"text"`
+		Geo  nestedGeo `form:"geo"`
+	}
+
+	tests := []struct {
+		name string
+		src  interface{}
+		want string
+	}{{
+		// The example from RFC 5849, section 3.4.1.3.2.
+		name: "oauth parameters",
+		src: map[string][]string{
+			"b5":                     {"=%3D"},
+			"a3":                     {"a", "2 q"},
+			"c@":                     {""},
+			"a2":                     {"r b"},
+			"oauth_consumer_key":     {"9djdj82h48djs9d2"},
+			"oauth_token":            {"kkk9d7dh3k39sjv7"},
+			"oauth_signature_method": {"HMAC-SHA1"},
+			"oauth_timestamp":        {"137131201"},
+			"oauth_nonce":            {"7d8f3e4a"},
+			"c2":                     {""},
+		},
+		want: "a2=r%20b&a3=2%20q&a3=a&b5=%3D%253D&c%40=&c2=&oauth_consumer_key=9djdj82h48djs9d2" +
+			"&oauth_nonce=7d8f3e4a&oauth_signature_method=HMAC-SHA1&oauth_timestamp=137131201" +
+			"&oauth_token=kkk9d7dh3k39sjv7",
+	}, {
+		name: "struct",
+		src: &params{Z: "last", A: []string{"b", "a", "B"}, Text: "a b+c/ü",
+			Geo: nestedGeo{Lat: 1, Lng: 2}},
+		want: "a=B&a=a&a=b&geo.lat=1&geo.lng=2&text=a%20b%2Bc%2F%C3%BC&z=last",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := NewEncoder(&b).Canonical().Encode(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderCanonicalReuse(t *testing.T) {
+	var b strings.Builder
+	e := NewEncoder(&b).Canonical()
+
+	// a failed call leaves nothing behind for the next one
+	fail := true
+	e.RegisterConverter(reflect.TypeOf(0), func(v reflect.Value) (string, error) {
+		if fail && v.Int() == 2 {
+			return "", errors.New("fail")
+		}
+		return "n", nil
+	})
+	src := map[string]int{"b": 1, "c": 2}
+	if err := e.Encode(src); err == nil {
+		t.Fatal("got nil error, want an error")
+	}
+
+	fail = false
+	if err := e.Encode(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "b=n&c=na=n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
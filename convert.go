@@ -0,0 +1,97 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RegisterConverter registers the function fn as the converter of the
+// values of type t, and of pointers to t. The Decoder calls fn to decode
+// each value whose destination is of type t, instead of the built-in
+// parsers or the type's UnmarshalText method, which makes it possible to
+// decode types that the caller does not own, e.g. sql.NullString. The
+// value returned by fn must be assignable to t. Empty values are handled
+// according to the Decoder's empty mode and are not passed to fn.
+func (d *Decoder) RegisterConverter(t reflect.Type, fn func(string) (reflect.Value, error)) *Decoder {
+	if d.converters == nil {
+		d.converters = make(map[reflect.Type]func(string) (reflect.Value, error))
+	}
+	d.converters[t] = fn
+	return d
+}
+
+// hasConverter reports whether a converter is registered
+// for the type t, or for the type that t points to.
+func (d *Decoder) hasConverter(t reflect.Type) bool {
+	if _, ok := d.converters[t]; ok {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		_, ok := d.converters[t.Elem()]
+		return ok
+	}
+	return false
+}
+
+// convert decodes the string src into dst using the converter registered
+// for dst's type, or for the type that dst points to. The ok return value
+// reports whether such a converter is registered.
+func (d *Decoder) convert(dst reflect.Value, src string) (ok bool, err error) {
+	fn, ok := d.converters[dst.Type()]
+	if !ok && dst.Kind() == reflect.Ptr {
+		if fn, ok = d.converters[dst.Type().Elem()]; ok {
+			if dst.IsNil() {
+				dst.Set(reflect.New(dst.Type().Elem()))
+			}
+			dst = dst.Elem()
+		}
+	}
+	if !ok {
+		return false, nil
+	}
+
+	v, err := fn(src)
+	if err != nil {
+		return true, err
+	}
+	if !v.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return true, nil
+	}
+	if !v.Type().AssignableTo(dst.Type()) {
+		return true, fmt.Errorf("converter returned a value of type %s", v.Type())
+	}
+	dst.Set(v)
+	return true, nil
+}
+
+// RegisterConverter registers the function fn as the converter of the
+// values of type t, and of non-nil pointers to t. The Encoder calls fn
+// to encode each value of type t instead of the built-in formatters or
+// the type's MarshalText method.
+func (e *Encoder) RegisterConverter(t reflect.Type, fn func(reflect.Value) (string, error)) *Encoder {
+	if e.converters == nil {
+		e.converters = make(map[reflect.Type]func(reflect.Value) (string, error))
+	}
+	e.converters[t] = fn
+	return e
+}
+
+// convert encodes rv using the converter registered for its type, or for
+// the type of the value it points to. The ok return value reports whether
+// such a converter is registered.
+func (e *Encoder) convert(rv reflect.Value) (s string, ok bool, err error) {
+	if len(e.converters) == 0 {
+		return "", false, nil
+	}
+	for {
+		if fn, ok := e.converters[rv.Type()]; ok {
+			s, err := fn(rv)
+			return s, true, err
+		}
+		if rv.Kind() != reflect.Ptr && rv.Kind() != reflect.Interface || rv.IsNil() {
+			return "", false, nil
+		}
+		rv = rv.Elem()
+	}
+}
@@ -0,0 +1,110 @@
+package form
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cents is a type with unexported fields that the package cannot decode on its own.
+type cents struct{ n int64 }
+
+type convertType struct {
+	Name   sql.NullString  `form:"name"`
+	Note   *sql.NullString `form:"note"`
+	Price  cents           `form:"price"`
+	Prices []cents         `form:"prices"`
+}
+
+var errBadCents = errors.New("bad cents")
+
+func parseCents(s string) (reflect.Value, error) {
+	i := strings.IndexByte(s, '.')
+	if i < 0 || len(s)-i != 3 {
+		return reflect.Value{}, errBadCents
+	}
+	n, err := strconv.ParseInt(s[:i]+s[i+1:], 10, 64)
+	if err != nil {
+		return reflect.Value{}, errBadCents
+	}
+	return reflect.ValueOf(cents{n}), nil
+}
+
+func formatCents(v reflect.Value) (string, error) {
+	n := v.Interface().(cents).n
+	return strconv.FormatInt(n/100, 10) + "." + strconv.FormatInt(n%100+100, 10)[1:], nil
+}
+
+func newConvertDecoder(data string) *Decoder {
+	return NewDecoder(strings.NewReader(data)).
+		RegisterConverter(reflect.TypeOf(sql.NullString{}), func(s string) (reflect.Value, error) {
+			return reflect.ValueOf(sql.NullString{String: s, Valid: true}), nil
+		}).
+		RegisterConverter(reflect.TypeOf(cents{}), parseCents)
+}
+
+func TestDecoderRegisterConverter(t *testing.T) {
+	tests := []struct {
+		data string
+		want *convertType
+		err  error
+	}{{
+		data: "name=joe&note=hi&price=1.50&prices=0.99&prices=10.00",
+		want: &convertType{
+			Name:   sql.NullString{String: "joe", Valid: true},
+			Note:   &sql.NullString{String: "hi", Valid: true},
+			Price:  cents{150},
+			Prices: []cents{{99}, {1000}},
+		},
+	}, {
+		data: "name=&price=1.5",
+		err:  &ValueError{Key: "price", Value: "1.5", Field: "Price", Type: reflect.TypeOf(cents{}), Err: errBadCents},
+	}}
+
+	for i, tt := range tests {
+		dst := new(convertType)
+		err := newConvertDecoder(tt.data).Decode(dst)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("#%d: got error %v, want %v", i, err, tt.err)
+		} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("#%d: got %+v, want %+v", i, dst, tt.want)
+		}
+	}
+}
+
+func TestDecoderRegisterConverterType(t *testing.T) {
+	d := NewDecoder(strings.NewReader("price=1")).
+		RegisterConverter(reflect.TypeOf(cents{}), func(s string) (reflect.Value, error) {
+			return reflect.ValueOf(s), nil
+		})
+	err := d.Decode(new(convertType))
+	if verr, ok := err.(*ValueError); !ok || verr.Err == nil || verr.Key != "price" {
+		t.Errorf("got error %v, want a *ValueError for key price", err)
+	}
+}
+
+func TestEncoderRegisterConverter(t *testing.T) {
+	src := &convertType{
+		Name:   sql.NullString{String: "joe", Valid: true},
+		Price:  cents{150},
+		Prices: []cents{{99}, {1000}},
+	}
+	want := url.Values{"name": {"joe"}, "price": {"1.50"}, "prices": {"0.99", "10.00"}}
+
+	var b strings.Builder
+	e := NewEncoder(&b).
+		RegisterConverter(reflect.TypeOf(sql.NullString{}), func(v reflect.Value) (string, error) {
+			return v.Interface().(sql.NullString).String, nil
+		}).
+		RegisterConverter(reflect.TypeOf(cents{}), formatCents)
+	if err := e.Encode(src); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := url.ParseQuery(b.String()); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v (%v), want %v", got, err, want)
+	}
+}
@@ -0,0 +1,70 @@
+package form
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrEmptyValue is the error wrapped by the ValueErrors of empty values
+// returned by a Decoder whose empty mode is EmptyError.
+var ErrEmptyValue = errors.New("empty value")
+
+// EmptyMode specifies how a Decoder handles empty values, e.g. "age=".
+type EmptyMode uint8
+
+const (
+	// EmptySkip leaves the field of an empty value as it is. A value
+	// that implements encoding.TextUnmarshaler is still passed the empty
+	// value. This is the default mode.
+	EmptySkip EmptyMode = iota
+	// EmptyZero sets the field of an empty value to its zero value,
+	// a pointer field is set to a pointer to the zero value.
+	EmptyZero
+	// EmptyNil sets the field of an empty value to nil, if it's a pointer,
+	// slice, map or interface, or otherwise to its zero value.
+	EmptyNil
+	// EmptyError causes the Decoder to return a ValueError that wraps
+	// ErrEmptyValue for every empty value.
+	EmptyError
+)
+
+// WithEmptyMode sets how the Decoder handles empty values. The mode of a
+// single field can be overridden with the "empty" option of its tag, whose
+// value is one of "skip", "zero", "nil" or "error", e.g. `form:"age,empty=zero"`.
+func (d *Decoder) WithEmptyMode(mode EmptyMode) *Decoder {
+	d.empty = mode
+	return d
+}
+
+// parseEmptyMode returns the EmptyMode with the given name.
+func parseEmptyMode(name string) (EmptyMode, bool) {
+	switch name {
+	case "skip":
+		return EmptySkip, true
+	case "zero":
+		return EmptyZero, true
+	case "nil":
+		return EmptyNil, true
+	case "error":
+		return EmptyError, true
+	}
+	return EmptySkip, false
+}
+
+// setEmpty sets dst as specified by the given mode
+// for the fields whose value was empty.
+func setEmpty(dst reflect.Value, mode EmptyMode) error {
+	switch mode {
+	case EmptyZero:
+		if dst.Kind() == reflect.Ptr {
+			dst.Set(reflect.New(dst.Type().Elem()))
+			return nil
+		}
+		dst.Set(reflect.Zero(dst.Type()))
+	case EmptyNil:
+		dst.Set(reflect.Zero(dst.Type()))
+	case EmptyError:
+		return ErrEmptyValue
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type emptyType struct {
+	Age  int            `form:"age"`
+	Name *string        `form:"name"`
+	Ints []int          `form:"ints"`
+	Text *marshalSlice  `form:"text"`
+	Meta map[string]int `form:"meta"`
+	Skip int            `form:"skip,empty=skip"`
+	Zero *int           `form:"zero,empty=zero"`
+}
+
+func newEmptyType() *emptyType {
+	return &emptyType{
+		Age:  30,
+		Name: strp("joe"),
+		Ints: []int{1},
+		Text: &marshalSlice{"a"},
+		Meta: map[string]int{"a": 1},
+		Skip: 5,
+		Zero: intp(5),
+	}
+}
+
+func TestDecoderEmptyMode(t *testing.T) {
+	const data = "age=&name=&ints=1&ints=&ints=3&text=&meta.a=&skip=&zero="
+
+	tests := []struct {
+		name string
+		mode EmptyMode
+		want *emptyType
+		err  error
+	}{{
+		name: "skip",
+		mode: EmptySkip,
+		want: &emptyType{Age: 30, Name: strp("joe"), Ints: []int{1, 0, 3}, Text: &marshalSlice{"a", ""},
+			Meta: map[string]int{"a": 1}, Skip: 5, Zero: intp(0)},
+	}, {
+		name: "zero",
+		mode: EmptyZero,
+		want: &emptyType{Age: 0, Name: strp(""), Ints: []int{1, 0, 3}, Text: new(marshalSlice),
+			Meta: map[string]int{"a": 0}, Skip: 5, Zero: intp(0)},
+	}, {
+		name: "nil",
+		mode: EmptyNil,
+		want: &emptyType{Age: 0, Name: nil, Ints: []int{1, 0, 3}, Text: nil,
+			Meta: map[string]int{"a": 0}, Skip: 5, Zero: intp(0)},
+	}, {
+		name: "error",
+		mode: EmptyError,
+		err:  &ValueError{Key: "age", Value: "", Field: "Age", Type: reflect.TypeOf(0), Err: ErrEmptyValue},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := newEmptyType()
+			d := NewDecoder(strings.NewReader(data)).WithEmptyMode(tt.mode)
+			if err := d.Decode(dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("got %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderEmptyModeErrors(t *testing.T) {
+	const data = "age=&name=&ints=1&ints=&text=&meta.a=&skip=&zero="
+	want := DecodeErrors{
+		&ValueError{Key: "age", Value: "", Field: "Age", Type: reflect.TypeOf(0), Err: ErrEmptyValue},
+		&ValueError{Key: "name", Value: "", Field: "Name", Type: reflect.TypeOf(strp("")), Err: ErrEmptyValue},
+		&ValueError{Key: "ints", Value: "", Index: 1, Field: "Ints[1]", Type: reflect.TypeOf(0), Err: ErrEmptyValue},
+		&ValueError{Key: "text", Value: "", Field: "Text", Type: reflect.TypeOf(&marshalSlice{}), Err: ErrEmptyValue},
+		&ValueError{Key: "meta.a", Value: "", Field: `Meta["a"]`, Type: reflect.TypeOf(0), Err: ErrEmptyValue},
+	}
+	d := NewDecoder(strings.NewReader(data)).WithEmptyMode(EmptyError).CollectErrors()
+	if err := d.Decode(newEmptyType()); !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+
+	dst := new(struct {
+		Age int `form:"age,empty=none"`
+	})
+	if err := Unmarshal([]byte("age="), dst); err == nil || !strings.Contains(err.Error(), "invalid empty option") {
+		t.Errorf("got error %v, want invalid empty option error", err)
+	}
+}
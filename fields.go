@@ -0,0 +1,99 @@
+package form
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A field holds the information about a struct field, derived from its
+// type and tag, that's needed to decode and encode the field's value.
+type field struct {
+	index     int
+	name      string
+	key       string // the key from the tag, or the field's name
+	opts      tagOptions
+	tagged    bool // the field has a tag with the key
+	ignored   bool // the tag is "-", or the field is bound to a header or a cookie
+	anonymous bool
+
+	omitempty  bool
+	layout     string
+	def        string
+	hasDefault bool
+	emptyOpt   string // the value of the "empty" option
+	hasEmpty   bool
+	empty      EmptyMode
+	emptyValid bool
+	source     string // the value of the "source" option
+}
+
+// fieldsKey identifies the fields of a struct type as described
+// by the tags with a specific key.
+type fieldsKey struct {
+	t      reflect.Type
+	tagKey string
+}
+
+// fieldCache maps the fieldsKeys to the []field
+// slices that describe their struct types.
+var fieldCache sync.Map // map[fieldsKey][]field
+
+// cachedFields returns the fields of the struct type t, as described by
+// their tags with the given key. The unexported fields that are not
+// embedded are omitted. The fields are computed once per type and key.
+func cachedFields(t reflect.Type, tagKey string) []field {
+	k := fieldsKey{t, tagKey}
+	if f, ok := fieldCache.Load(k); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(k, typeFields(t, tagKey))
+	return f.([]field)
+}
+
+// typeFields returns the fields of the struct type t, as described
+// by their tags with the given key.
+func typeFields(t reflect.Type, tagKey string) []field {
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get(tagKey)
+		key, opts := parseTag(tag)
+		if key == "" {
+			key = sf.Name
+		}
+		f := field{
+			index:     i,
+			name:      sf.Name,
+			key:       key,
+			opts:      opts,
+			tagged:    tag != "",
+			ignored:   tag == "-" || (tag == "" && isBoundElsewhere(sf.Tag, tagKey)),
+			anonymous: sf.Anonymous,
+			omitempty: opts.Contains("omitempty"),
+		}
+		f.layout, _ = opts.Value("layout")
+		f.def, f.hasDefault = opts.Value("default")
+		f.source, _ = opts.Value("source")
+		if f.emptyOpt, f.hasEmpty = opts.Value("empty"); f.hasEmpty {
+			f.empty, f.emptyValid = parseEmptyMode(f.emptyOpt)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// isBoundElsewhere reports whether the struct tag binds its field to a
+// header or a cookie, with a tag whose key is other than the given key.
+// Such fields are decoded and encoded only under the keys of their tags.
+func isBoundElsewhere(tag reflect.StructTag, tagKey string) bool {
+	for _, k := range []string{HeaderTagKey, CookieTagKey} {
+		if k != tagKey && tag.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package form
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type (
+	Embed struct{ X int }
+	embed struct{ Y int }
+)
+
+func TestCachedFields(t *testing.T) {
+	type T struct {
+		A      string `form:"a,omitempty" json:"x"`
+		B      int    `form:"-" json:"b,default=1"`
+		c      bool
+		Embed         // embedded and exported
+		*embed        // embedded and unexported
+		D      []int  `form:",empty=zero,layout=date"`
+		E      string `form:"e,empty=bogus"`
+	}
+	rt := reflect.TypeOf(T{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cachedFields(rt, "form")
+		}()
+	}
+	wg.Wait()
+
+	fields := cachedFields(rt, "form")
+	got := make([]string, len(fields))
+	for i, f := range fields {
+		got[i] = f.key
+	}
+	if want := []string{"a", "-", "Embed", "embed", "D", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+	if f := fields[0]; !f.omitempty || f.index != 0 {
+		t.Errorf("got %+v, want omitempty field at index 0", f)
+	}
+	if f := fields[1]; !f.ignored {
+		t.Errorf("got %+v, want ignored field", f)
+	}
+	if f := fields[4]; f.index != 5 || !f.hasEmpty || !f.emptyValid || f.empty != EmptyZero || f.layout != "date" {
+		t.Errorf("got %+v, want field D with empty=zero and layout=date", f)
+	}
+	if f := fields[5]; !f.hasEmpty || f.emptyValid {
+		t.Errorf("got %+v, want field e with an invalid empty option", f)
+	}
+
+	// the fields are cached per tag key
+	if f := cachedFields(rt, "json"); f[0].key != "x" || !f[1].hasDefault || f[1].def != "1" {
+		t.Errorf("got %+v, want fields described by the json tags", f)
+	}
+}
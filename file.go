@@ -0,0 +1,103 @@
+package form
+
+import (
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+)
+
+// File represents a file uploaded as part of a "multipart/form-data" body.
+type File struct {
+	// The name of the file as provided by the client.
+	Name string
+	// The size of the file in bytes.
+	Size int64
+	// The MIME header of the file's part.
+	Header textproto.MIMEHeader
+
+	fh *multipart.FileHeader
+}
+
+// ContentType returns the value of the file's Content-Type header.
+func (f *File) ContentType() string {
+	return f.Header.Get("Content-Type")
+}
+
+// Open opens and returns the file's content.
+func (f *File) Open() (multipart.File, error) {
+	return f.fh.Open()
+}
+
+// FileHeader returns the *multipart.FileHeader that describes the file.
+func (f *File) FileHeader() *multipart.FileHeader {
+	return f.fh
+}
+
+func newFile(fh *multipart.FileHeader) File {
+	return File{Name: fh.Filename, Size: fh.Size, Header: fh.Header, fh: fh}
+}
+
+var (
+	fileType       = reflect.TypeOf(File{})
+	filePtrType    = reflect.TypeOf(&File{})
+	fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+	bytesType      = reflect.TypeOf([]byte(nil))
+)
+
+// isFileType reports whether the type t can hold an uploaded file or,
+// if t is a slice (other than []byte), whether its elements can.
+func isFileType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t != bytesType {
+		t = t.Elem()
+	}
+	switch t {
+	case fileType, filePtrType, fileHeaderType, bytesType:
+		return true
+	}
+	return false
+}
+
+// decodeFiles stores the given files into the value dst which must be of
+// one of the types accepted by isFileType. If dst is not a slice only the
+// first file is stored.
+func decodeFiles(dst reflect.Value, fhs []*multipart.FileHeader) error {
+	if dst.Kind() != reflect.Slice || dst.Type() == bytesType {
+		return decodeFile(dst, fhs[0])
+	}
+
+	sl := reflect.MakeSlice(dst.Type(), len(fhs), len(fhs))
+	for i, fh := range fhs {
+		if err := decodeFile(sl.Index(i), fh); err != nil {
+			return err
+		}
+	}
+	dst.Set(sl)
+	return nil
+}
+
+// decodeFile stores the file described by fh into the value dst.
+func decodeFile(dst reflect.Value, fh *multipart.FileHeader) error {
+	switch dst.Type() {
+	case fileType:
+		dst.Set(reflect.ValueOf(newFile(fh)))
+	case filePtrType:
+		f := newFile(fh)
+		dst.Set(reflect.ValueOf(&f))
+	case fileHeaderType:
+		dst.Set(reflect.ValueOf(fh))
+	case bytesType:
+		f, err := fh.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		dst.SetBytes(b)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package form
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// multipartBody returns a "multipart/form-data" body containing
+// the given values and files, and the body's content type.
+func multipartBody(t *testing.T, values [][2]string, files [][3]string) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for _, v := range values {
+		if err := mw.WriteField(v[0], v[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		w, err := mw.CreateFormFile(f[0], f[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f[2])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, mw.FormDataContentType()
+}
+
+type uploadType struct {
+	Title   string                  `form:"title"`
+	Avatar  *multipart.FileHeader   `form:"avatar"`
+	Photos  []*multipart.FileHeader `form:"photos"`
+	Raw     []byte                  `form:"raw"`
+	Raws    [][]byte                `form:"raws"`
+	Doc     File                    `form:"doc"`
+	DocPtr  *File                   `form:"docptr"`
+	Docs    []File                  `form:"docs"`
+	Missing *File                   `form:"missing"`
+}
+
+func TestDecoderMultipartFiles(t *testing.T) {
+	body, ctype := multipartBody(t, [][2]string{
+		{"title", "Hello"},
+	}, [][3]string{
+		{"avatar", "me.png", "png-data"},
+		{"photos", "a.jpg", "aaa"},
+		{"photos", "b.jpg", "bbbb"},
+		{"raw", "raw.txt", "raw content"},
+		{"raws", "1.txt", "one"},
+		{"raws", "2.txt", "two"},
+		{"doc", "doc.pdf", "pdf"},
+		{"docptr", "doc2.pdf", "pdf2"},
+		{"docs", "x.txt", "x"},
+		{"docs", "y.txt", "yy"},
+	})
+
+	d := NewDecoderMultipart(body, ctype)
+	defer d.RemoveAll()
+
+	dst := new(uploadType)
+	if err := d.Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.Title != "Hello" {
+		t.Errorf("Title got %q, want %q", dst.Title, "Hello")
+	}
+	if dst.Avatar == nil || dst.Avatar.Filename != "me.png" || dst.Avatar.Size != 8 {
+		t.Errorf("Avatar got %+v", dst.Avatar)
+	}
+	if len(dst.Photos) != 2 || dst.Photos[0].Filename != "a.jpg" || dst.Photos[1].Filename != "b.jpg" {
+		t.Errorf("Photos got %+v", dst.Photos)
+	}
+	if got := string(dst.Raw); got != "raw content" {
+		t.Errorf("Raw got %q, want %q", got, "raw content")
+	}
+	if want := [][]byte{[]byte("one"), []byte("two")}; !reflect.DeepEqual(dst.Raws, want) {
+		t.Errorf("Raws got %q, want %q", dst.Raws, want)
+	}
+	if dst.Doc.Name != "doc.pdf" || dst.Doc.Size != 3 || dst.Doc.ContentType() != "application/octet-stream" {
+		t.Errorf("Doc got %+v", dst.Doc)
+	}
+	if dst.DocPtr == nil || dst.DocPtr.Name != "doc2.pdf" {
+		t.Errorf("DocPtr got %+v", dst.DocPtr)
+	}
+	if len(dst.Docs) != 2 || dst.Docs[0].Name != "x.txt" || dst.Docs[1].Size != 2 {
+		t.Errorf("Docs got %+v", dst.Docs)
+	}
+	if dst.Missing != nil {
+		t.Errorf("Missing got %+v, want nil", dst.Missing)
+	}
+
+	f, err := dst.Docs[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(f); err != nil {
+		t.Fatal(err)
+	} else if buf.String() != "yy" {
+		t.Errorf("Docs[1] content got %q, want %q", buf.String(), "yy")
+	}
+}
+
+func TestDecoderMultipartLimits(t *testing.T) {
+	values := [][2]string{{"title", "Hello"}, {"tags", "a"}, {"tags", "b"}}
+	files := [][3]string{{"photos", "a.jpg", "aaa"}, {"photos", "b.jpg", "bbbbbbbb"}}
+
+	tests := []struct {
+		name   string
+		limits Limits
+		err    error
+	}{{
+		name:   "within limits",
+		limits: Limits{MaxMemory: 4, MaxFileSize: 8, MaxParts: 5, MaxBodySize: 1 << 20},
+	}, {
+		name:   "file too large",
+		limits: Limits{MaxFileSize: 7},
+		err:    &LimitError{Limit: "MaxFileSize", Max: 7, Value: 8, Key: "photos"},
+	}, {
+		name:   "too many parts",
+		limits: Limits{MaxParts: 4},
+		err:    &LimitError{Limit: "MaxParts", Max: 4, Value: 5},
+	}, {
+		name:   "body too large",
+		limits: Limits{MaxBodySize: 100},
+		err:    &LimitError{Limit: "MaxBodySize", Max: 100, Value: 101},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ctype := multipartBody(t, values, files)
+			d := NewDecoderMultipart(body, ctype).WithLimits(tt.limits)
+			defer d.RemoveAll()
+
+			dst := new(struct {
+				Title  string                  `form:"title"`
+				Tags   []string                `form:"tags"`
+				Photos []*multipart.FileHeader `form:"photos"`
+			})
+			if err := d.Decode(dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			} else if err == nil && (dst.Title != "Hello" || len(dst.Tags) != 2 || len(dst.Photos) != 2) {
+				t.Errorf("got %+v", dst)
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestDecoderMultipartLimitsEarly(t *testing.T) {
+	large := strings.Repeat("x", 5<<20)
+	tests := []struct {
+		name   string
+		limits Limits
+		files  [][3]string
+		err    error
+	}{{
+		name:   "file too large",
+		limits: Limits{MaxFileSize: 10},
+		files:  [][3]string{{"photos", "a.jpg", large}},
+		err:    &LimitError{Limit: "MaxFileSize", Max: 10, Value: 11, Key: "photos"},
+	}, {
+		name:   "too many parts",
+		limits: Limits{MaxParts: 1},
+		files:  [][3]string{{"photos", "a.jpg", "a"}, {"photos", "b.jpg", "b"}, {"photos", "c.jpg", large}},
+		err:    &LimitError{Limit: "MaxParts", Max: 1, Value: 2},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ctype := multipartBody(t, nil, tt.files)
+			size := body.Len()
+			cr := &countingReader{r: body}
+			d := NewDecoderMultipart(cr, ctype).WithLimits(tt.limits)
+			defer d.RemoveAll()
+
+			dst := new(struct {
+				Photos []*multipart.FileHeader `form:"photos"`
+			})
+			if err := d.Decode(dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if cr.n >= size/2 {
+				t.Errorf("read %d bytes of the %d byte body", cr.n, size)
+			}
+		})
+	}
+}
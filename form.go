@@ -10,15 +10,19 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// TODO: support for "multipart/form-data"
-
 // The ArgumentError will be returned by one of the package's expored functions
-// or methods if the argument passed to them is not a non-nil pointer to a struct.
+// or methods if the argument passed to them is not a non-nil pointer to a struct
+// or to a map with string keys.
 type ArgumentError struct {
 	Type reflect.Type
 }
@@ -32,79 +36,225 @@ func (e *ArgumentError) Error() string {
 	} else {
 		t = e.Type.String()
 	}
-	return "form: the v interface{} argument must be a non-nil pointer to a struct, instead got " + t
+	return "form: the v interface{} argument must be a non-nil pointer to a struct or a map, instead got " + t
 }
 
 // A ValueError describes a URL-encoded value that was not
 // appropriate for a value of a specific Go type.
 type ValueError struct {
-	Key   string
+	// The key of the value, e.g. "address.geo.lat".
+	Key string
+	// The value that could not be decoded.
 	Value string
-	Type  string
+	// The position of the value among the values of its key.
+	Index int
+	// The Go path of the struct field, or of the element of a slice
+	// or map, into which the value was being decoded, e.g.
+	// "Address.Geo.Lat", "Items[2].Qty" or `Meta["color"]`.
+	Field string
+	// The Go type into which the value was being decoded.
+	Type reflect.Type
+	// The error returned by the parser, or by UnmarshalText.
+	Err error
 }
 
 func (err *ValueError) Error() string {
-	return fmt.Sprintf("form: %q value %q could not be parsed into %q", err.Key, err.Value, err.Type)
+	cause := err.Err
+	if ne, ok := cause.(*strconv.NumError); ok {
+		cause = ne.Err
+	}
+	if cause == nil {
+		return fmt.Sprintf("form: %q value %q could not be parsed into %s", err.Key, err.Value, err.Type)
+	}
+	return fmt.Sprintf("form: %q value %q could not be parsed into %s: %v", err.Key, err.Value, err.Type, cause)
+}
+
+// Unwrap returns the underlying error.
+func (err *ValueError) Unwrap() error {
+	return err.Err
+}
+
+// An UnknownKeysError is returned by a Decoder that disallows unknown fields
+// if its input contains keys that do not match any field of the destination.
+type UnknownKeysError struct {
+	// The sorted list of the unknown keys.
+	Keys []string
+}
+
+func (err *UnknownKeysError) Error() string {
+	keys := make([]string, len(err.Keys))
+	for i, k := range err.Keys {
+		keys[i] = strconv.Quote(k)
+	}
+	return "form: unknown keys " + strings.Join(keys, ", ")
+}
+
+// An IndexError describes an index, in the key of an element of
+// an indexed slice, that was not acceptable to the Decoder.
+type IndexError struct {
+	// The key of the slice element, e.g. "items.7".
+	Key string
+	// The offending index.
+	Index int
+	// The maximum index allowed by the Decoder, or -1 if the
+	// index was rejected because it left a gap in the slice.
+	Max int
+}
+
+func (err *IndexError) Error() string {
+	if err.Max < 0 {
+		return fmt.Sprintf("form: %q index %d leaves a gap in the slice", err.Key, err.Index)
+	}
+	return fmt.Sprintf("form: %q index %d exceeds the maximum index %d", err.Key, err.Index, err.Max)
+}
+
+// DecodeErrors is the list of errors returned by a Decoder that collects
+// errors, it contains an error for every value that could not be decoded.
+type DecodeErrors []error
+
+func (errs DecodeErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
 }
 
 // Unmarshal parses the URL-encoded data and stores the result in the value
-// pointed to by v. The v argument must point to a struct value otherwhise
-// an ArgumentError will be returned.
+// pointed to by v. The v argument must point to a struct or a map value
+// otherwhise an ArgumentError will be returned.
 func Unmarshal(data []byte, v interface{}) error {
-	src, err := parseBytes(data)
+	if max := DefaultLimits.MaxBodySize; max > 0 && int64(len(data)) > max {
+		return &LimitError{Limit: "MaxBodySize", Max: max, Value: int64(len(data))}
+	}
+	src, err := parseBytes(data, DefaultLimits)
 	if err != nil {
 		return err
 	}
-	d := &Decoder{
-		src:  src,
-		done: make(map[string]bool),
-	}
-	return d.Decode(v)
+	return newDecoder(src).Decode(v)
 }
 
 // Transform takes the url.Values src and stores its elements into the value
-// pointed to by dst. The dst argument must point to a struct value otherwhise
-// an ArgumentError will be returned.
+// pointed to by dst. The dst argument must point to a struct or a map value
+// otherwhise an ArgumentError will be returned.
 func Transform(src url.Values, dst interface{}) error {
-	d := &Decoder{
-		src:  map[string][]string(src),
-		done: make(map[string]bool),
-	}
-	return d.Decode(dst)
+	return newDecoder(map[string][]string(src)).Decode(dst)
 }
 
 const (
 	defaultMaxMemory = 32 << 20 // 32 MB
+	defaultMaxIndex  = 1000
 )
 
 // A Decoder reads and decodes URL-encoded values.
+//
+// The "default" option of a struct field's tag specifies the value to be
+// decoded into the field if its key is absent from the input or if its
+// values are empty, e.g. `form:"page,default=1"`. The default of a slice
+// field is a list of "|" separated values, e.g. `form:"tags,default=a|b"`.
+// A default value cannot contain commas. The defaults of the fields of a
+// nested struct are decoded even if none of the struct's keys is present,
+// a nil pointer to such a struct is allocated, unless the struct's type is
+// recursive.
+//
+// Fields of type time.Time and time.Duration are decoded without the help
+// of encoding.TextUnmarshaler. The "layout" option of a time field's tag
+// specifies the layout of its values, either as a layout accepted by
+// time.Parse, e.g. `form:"at,layout=02/01/2006"`, or as the name of one
+// of the presets "date", "datetime-local", "month", "week" and "time",
+// e.g. `form:"birthday,layout=date"`. Without a layout the values are
+// parsed as RFC 3339 times. Durations are parsed by time.ParseDuration,
+// or as integer numbers of nanoseconds. A layout cannot contain commas.
 type Decoder struct {
 	tagKey string // TODO export
-
-	src  map[string][]string
-	done map[string]bool
-	err  error
-
-	vals []string
-	key  string
+	style  PathStyle
+
+	maxIndex     int
+	strictSparse bool
+	limits       Limits
+	stream       bool
+	strictKeys   bool
+	collect      bool
+	validate     bool
+	empty        EmptyMode
+	loc          *time.Location
+	converters   map[reflect.Type]func(string) (reflect.Value, error)
+
+	// the multipart body and its boundary, read on the first call to Decode
+	r        io.Reader
+	boundary string
+
+	// the request whose URL query is merged with the body
+	// on the first call to Decode, and the merge's precedence
+	req        *http.Request
+	precedence Precedence
+
+	// the values of the request's query and body, kept
+	// apart for the fields whose tags specify their source
+	query, body map[string][]string
+
+	// the values of the request's headers and cookies
+	header, cookie map[string][]string
+
+	src   map[string][]string
+	keys  []string // the sorted keys of src, see keysWithPrefix
+	files map[string][]*multipart.FileHeader
+	form  *multipart.Form
+	done  map[string]bool
+	err   error
+	errs  DecodeErrors
+
+	// the Go paths of the fields present in the input
+	present map[string]bool
+
+	// the absentTypes of the nested struct types, see absentType
+	absentTypes map[reflect.Type]absentType
+
+	// the names of the streamed file parts that were discarded
+	discarded []string
 }
 
 // NewDecoder returns a new decoder that reads from r.
+// The input is read on the first call to Decode.
 func NewDecoder(r io.Reader) *Decoder {
+	d := newDecoder(nil)
+	d.r = r
+	return d
+}
+
+// readBody reads and parses the Decoder's URL-encoded
+// input and enforces the limits set on the Decoder.
+func (d *Decoder) readBody() error {
+	r := d.r
+	d.r = nil
+	if max := d.limits.MaxBodySize; max > 0 {
+		r = &limitReader{r: r, max: max, limit: "MaxBodySize"}
+	}
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
-		return &Decoder{err: err}
+		return err
 	}
+	d.src, err = parseBytes(data, d.limits)
+	return err
+}
 
-	src, err := parseBytes(data)
-	if err != nil {
-		return &Decoder{err: err}
+// newDecoder returns a new decoder with the given src
+// and with the rest of its fields set to their defaults.
+func newDecoder(src map[string][]string) *Decoder {
+	return &Decoder{
+		src:      src,
+		done:     make(map[string]bool),
+		maxIndex: defaultMaxIndex,
+		limits:   DefaultLimits,
 	}
-
-	return &Decoder{src: src, done: make(map[string]bool)}
 }
 
-// NewDecoderMultipart returns a new decoder that reads from r.
+// NewDecoderMultipart returns a new decoder that reads the "multipart/form-data"
+// body from r. The uploaded files can be decoded into fields of type File,
+// *multipart.FileHeader and []byte, or slices of those types. Files that
+// don't fit into memory are stored on disk in temporary files, which can
+// be removed with RemoveAll once the Decoder's no longer needed. The body
+// is read on the first call to Decode.
 func NewDecoderMultipart(r io.Reader, contentType string) *Decoder {
 	d, params, err := mime.ParseMediaType(contentType)
 	if err != nil || d != "multipart/form-data" {
@@ -114,18 +264,110 @@ func NewDecoderMultipart(r io.Reader, contentType string) *Decoder {
 	if !ok {
 		return &Decoder{err: http.ErrMissingBoundary}
 	}
-	mr := multipart.NewReader(r, boundary)
+	dec := newDecoder(nil)
+	dec.r = r
+	dec.boundary = boundary
+	return dec
+}
 
-	f, err := mr.ReadForm(defaultMaxMemory)
+// readMultipart reads the Decoder's multipart body
+// and enforces the limits set on the Decoder.
+func (d *Decoder) readMultipart() error {
+	r := d.r
+	d.r = nil
+	if max := d.limits.MaxBodySize; max > 0 {
+		r = &limitReader{r: r, max: max, limit: "MaxBodySize"}
+	}
+	maxMemory := d.limits.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+
+	// If the number of parts or the size of the files is limited, pass the
+	// parts to ReadForm through a pipe, enforcing the limits while they are
+	// read, so that the body is not read any further once a limit's exceeded.
+	body, boundary := r, d.boundary
+	wait := func() error { return nil }
+	if d.limits.MaxParts > 0 || d.limits.MaxFileSize > 0 {
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		errc := make(chan error, 1)
+		go func() {
+			err := d.copyParts(mw, r)
+			pw.CloseWithError(err)
+			errc <- err
+		}()
+		body, boundary = pr, mw.Boundary()
+		wait = func() error {
+			pr.Close()
+			return <-errc
+		}
+	}
+
+	f, err := multipart.NewReader(body, boundary).ReadForm(maxMemory)
+	if lerr, ok := wait().(*LimitError); ok {
+		if err == nil {
+			f.RemoveAll()
+		}
+		return lerr
+	}
 	if err != nil {
-		return &Decoder{err: err}
+		if lr, ok := r.(*limitReader); ok && lr.err != nil {
+			return lr.err
+		}
+		return err
 	}
+	d.form = f
 
-	src := make(map[string][]string)
+	d.src = make(map[string][]string)
 	for k, v := range f.Value {
-		src[k] = append(src[k], v...)
+		d.src[k] = append(d.src[k], v...)
+	}
+	d.files = f.File
+	return nil
+}
+
+// copyParts copies the parts of the multipart body read from r to mw, and
+// closes mw once all of them have been copied. It fails with a LimitError
+// as soon as the body exceeds the MaxParts or the MaxFileSize limit.
+func (d *Decoder) copyParts(mw *multipart.Writer, r io.Reader) error {
+	mr := multipart.NewReader(r, d.boundary)
+	for n := 1; ; n++ {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return mw.Close()
+		} else if err != nil {
+			return err
+		}
+		if max := d.limits.MaxParts; max > 0 && n > max {
+			return &LimitError{Limit: "MaxParts", Max: int64(max), Value: int64(n)}
+		}
+
+		w, err := mw.CreatePart(p.Header)
+		if err != nil {
+			return err
+		}
+		var pr io.Reader = p
+		if max := d.limits.MaxFileSize; max > 0 && p.FileName() != "" {
+			pr = &limitReader{r: p, max: max, limit: "MaxFileSize"}
+		}
+		if _, err := io.Copy(w, pr); err != nil {
+			if lr, ok := pr.(*limitReader); ok && lr.err != nil {
+				lr.err.Key = p.FormName()
+				return lr.err
+			}
+			return err
+		}
+	}
+}
+
+// RemoveAll removes any temporary files associated with the
+// multipart form that was read by the Decoder.
+func (d *Decoder) RemoveAll() error {
+	if d.form == nil {
+		return nil
 	}
-	return &Decoder{src: src, done: make(map[string]bool)}
+	return d.form.RemoveAll()
 }
 
 func (d *Decoder) WithTagKey(tagKey string) *Decoder {
@@ -133,10 +375,65 @@ func (d *Decoder) WithTagKey(tagKey string) *Decoder {
 	return d
 }
 
+// WithPathStyle sets the syntax the Decoder expects from the keys of nested
+// values. The default style is PathDot.
+func (d *Decoder) WithPathStyle(style PathStyle) *Decoder {
+	d.style = style
+	return d
+}
+
+// WithLimits sets the limits the Decoder imposes on its input. The
+// limits take effect only if set before the first call to Decode.
+func (d *Decoder) WithLimits(limits Limits) *Decoder {
+	d.limits = limits
+	return d
+}
+
+// WithMaxIndex sets the maximum index the Decoder accepts in the keys of
+// indexed slice elements, e.g. "items.7.qty", larger indexes result in an
+// IndexError. A negative n removes the limit. The default maximum is 1000.
+func (d *Decoder) WithMaxIndex(n int) *Decoder {
+	d.maxIndex = n
+	return d
+}
+
+// DisallowSparseIndexes causes the Decoder to return an IndexError when the
+// indexes of the elements of an indexed slice are not contiguous and start
+// at a number other than 0. By default the gaps between indexes are removed
+// and the elements are stored in the slice in the order of their indexes.
+func (d *Decoder) DisallowSparseIndexes() *Decoder {
+	d.strictSparse = true
+	return d
+}
+
+// DisallowUnknownFields causes the Decoder to return an UnknownKeysError
+// when the input contains keys that do not match any of the fields of the
+// destination value.
+func (d *Decoder) DisallowUnknownFields() *Decoder {
+	d.strictKeys = true
+	return d
+}
+
+// CollectErrors causes the Decoder to continue decoding after it fails to
+// decode a value and to return all of the encountered errors as DecodeErrors.
+// Errors not caused by the decoded values, e.g. a LimitError, are returned
+// as is.
+func (d *Decoder) CollectErrors() *Decoder {
+	d.collect = true
+	return d
+}
+
 // Decode reads the URL-encoded data from its input and stores it in the
-// value pointed to by v. The v argument must point to a struct value
-// otherwhise an ArgumentError will be returned.
+// value pointed to by v. The v argument must point to a struct or a map
+// value otherwhise an ArgumentError will be returned.
 func (d *Decoder) Decode(v interface{}) error {
+	if d.r != nil {
+		if d.boundary == "" {
+			d.err = d.readBody()
+		} else if !d.stream {
+			d.err = d.readMultipart()
+		}
+	}
 	if d.err != nil {
 		return d.err
 	}
@@ -144,116 +441,749 @@ func (d *Decoder) Decode(v interface{}) error {
 		d.tagKey = DefaultTagKey
 	}
 
-	rv, ok := structValueOf(v)
+	rv, ok := destValueOf(v)
 	if !ok {
 		return &ArgumentError{reflect.TypeOf(v)}
 	}
-	return d.decode(rv)
+	d.errs = nil
+	d.done = make(map[string]bool)
+	d.present = make(map[string]bool)
+	if d.r != nil {
+		if err := d.readStream(rv); err != nil {
+			return err
+		}
+	}
+	if d.req != nil {
+		if err := d.mergeQuery(); err != nil {
+			return err
+		}
+	}
+
+	d.keys = nil
+
+	var err error
+	if d.isValuesUnmarshaler(rv) {
+		err = d.unmarshalFormValues(rv, "", "")
+	} else if rv.Kind() == reflect.Map {
+		err = d.decodeMap(rv, "", "", fieldOptions{empty: d.empty})
+	} else {
+		err = d.decode(rv, "", "")
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.header != nil && rv.Kind() == reflect.Struct {
+		if err := d.decodeTagged(rv, HeaderTagKey, d.header, textproto.CanonicalMIMEHeaderKey, ""); err != nil {
+			return err
+		}
+		if err := d.decodeTagged(rv, CookieTagKey, d.cookie, nil, ""); err != nil {
+			return err
+		}
+	}
+
+	if d.strictKeys {
+		if keys := d.unknownKeys(); len(keys) > 0 {
+			if err := d.fieldError(&UnknownKeysError{Keys: keys}); err != nil {
+				return err
+			}
+		}
+	}
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// fieldError returns err, unless the Decoder collects errors, or validates
+// the decoded values, in which case err is added to the collection and nil
+// is returned.
+func (d *Decoder) fieldError(err error) error {
+	if d.collect || d.validate {
+		d.errs = append(d.errs, err)
+		return nil
+	}
+	return err
 }
 
 // The decode method decodes the Decoder's src values into the dst struct value.
-func (d *Decoder) decode(dst reflect.Value) error {
+// The prefix, if not empty, is the key of the struct field that holds dst and
+// it is used to construct the keys of dst's own fields, e.g. "address.street".
+// Similarly, the path is the Go path of the field that holds dst, e.g. "Address".
+func (d *Decoder) decode(dst reflect.Value, prefix, path string) error {
 	var (
-		n        = dst.NumField()
-		stype    = dst.Type()
+		fields   = cachedFields(dst.Type(), d.tagKey)
 		embedded = []reflect.Value{}
 	)
 
-	for i := 0; i < n; i++ {
-		field := stype.Field(i)
+	for i := range fields {
+		f := &fields[i]
+		if f.ignored {
+			continue
+		}
+		key := d.style.join(prefix, f.key)
 
-		// Skip fields that are unexported and are not embedded.
-		if field.PkgPath != "" && !field.Anonymous {
+		// If a field with this key was already decoded,
+		// continue to the next one.
+		if d.done[key] {
 			continue
 		}
 
-		tag := field.Tag.Get(d.tagKey)
-		if tag == "-" {
+		// If the field is a struct and it is embedded, "record" it
+		// and decode its fields after the main loop's done.
+		fv := dst.Field(f.index)
+		if fv.Kind() == reflect.Struct && f.anonymous && len(d.values(key)) == 0 {
+			embedded = append(embedded, fv)
 			continue
 		}
-		key, _ := parseTag(tag)
-		if key == "" {
-			key = field.Name
+
+		// If the field's tag specifies the source of its
+		// values, decode the field from that source.
+		fpath := joinPath(path, f.name)
+		restore, err := d.useSource(f, fpath)
+		if err != nil {
+			return err
+		}
+		err = d.decodeField(fv, f, key, fpath)
+		restore()
+		if err != nil {
+			return err
 		}
+	}
 
-		// If a field with this key was already decoded,
-		// continue to the next one.
-		if d.done[key] {
-			//fmt.Println("abc", field.Name)
-			continue
+	// Loop over all of the embedded struct values, if there were any, and decode them.
+	for _, v := range embedded {
+		if err := d.decode(v, prefix, path); err != nil {
+			return err
 		}
+	}
 
-		d.vals = d.src[key]
-		d.key = key
+	return nil
+}
 
-		fv := dst.Field(i)
-		fk := fv.Kind()
-		ln := len(d.vals)
+// decodeField decodes the values associated with the given key into the
+// value fv of the struct field f, whose Go path is the given path, and
+// validates the decoded value if the Decoder's validation is enabled.
+func (d *Decoder) decodeField(fv reflect.Value, f *field, key, path string) error {
+	fo, err := d.fieldOptions(f, path)
+	if err != nil {
+		return err
+	}
 
-		if ln == 0 {
-			// If the field is a struct and it is embedded, "record"
-			// it and decode its fields after the main loop's done.
-			if fk == reflect.Struct && field.Anonymous {
-				fmt.Println("abc", field.Name)
-				embedded = append(embedded, fv)
-			}
+	nerrs := len(d.errs)
+	defaulted := f.hasDefault && !d.isPresent(key)
+	if defaulted {
+		// If the key is absent, or its values are empty,
+		// decode the default value specified by the tag.
+		if err := d.decodeStrings(fv, key, path, splitDefault(fv.Type(), f.def), fo); err != nil {
+			return err
+		}
+	} else if err := d.decodeValue(fv, key, path, fo); err != nil {
+		return err
+	}
 
-			// If no value is associated with the key
-			// continue to the next field.
-			continue
+	// Validate the field unless its value could not be decoded.
+	if d.validate && f.opts != "" && !hasValueErrors(d.errs[nerrs:]) {
+		if err := d.validateField(fv, key, path, f.opts, defaulted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeValue decodes the values associated with the given key, or the
+// values nested inside the given key, into the dst value. The path is
+// the Go path of dst, e.g. "Address.Street" or "Items[2]". The field
+// options specify how to handle empty values and how to parse times.
+func (d *Decoder) decodeValue(dst reflect.Value, key, path string, fo fieldOptions) error {
+	if fhs := d.files[key]; len(fhs) > 0 && isFileType(dst.Type()) {
+		if err := decodeFiles(dst, fhs); err != nil {
+			return err
+		}
+		d.markDone(key)
+		d.present[path] = true
+		return nil
+	}
+
+	// If the value implements FormValuesUnmarshaler, pass the
+	// values of the key and the values nested inside the key
+	// to its UnmarshalForm method.
+	if d.isValuesUnmarshaler(dst) {
+		return d.unmarshalFormValues(dst, key, path)
+	}
+
+	vals := d.values(key)
+
+	fv := dst
+	fk := fv.Kind()
+
+	// If the value can be decoded from the values of
+	// the key itself, and there are any, decode them.
+	if len(vals) > 0 && d.holdsText(fv.Type()) {
+		d.present[path] = true
+		return d.decodeStrings(fv, key, path, vals, fo)
+	}
+
+	// If the value is a pointer to a map or a slice, decode the values into
+	// the map or slice it points to, which is allocated only if any value
+	// is stored.
+	if fk == reflect.Ptr && (fv.Type().Elem().Kind() == reflect.Map || fv.Type().Elem().Kind() == reflect.Slice) {
+		ev := fv
+		if fv.IsNil() {
+			ev = reflect.New(fv.Type().Elem())
 		}
+		if err := d.decodeValue(ev.Elem(), key, path, fo); err != nil {
+			return err
+		}
+		if fv.IsNil() && d.present[path] {
+			fv.Set(ev)
+		}
+		return nil
+	}
 
-		// If the value implements encoding.TextUnmarshaler, loop over
-		// the values and call its UnmarshalText method with each value.
-		if fk != reflect.Ptr && fv.CanAddr() && fv.Type().Name() != "" {
-			fv = fv.Addr()
+	if !d.hasPrefix(key) {
+		// If no value is associated with the key continue to the
+		// next field, unless the field is a struct whose fields
+		// must be decoded even if their keys are absent.
+		if d.isNestedStruct(fv.Type()) && d.absentType(fv.Type()).fill {
+			return d.decodeAbsent(fv, key, path)
 		}
-		if fv.IsValid() && fv.Type().NumMethod() > 0 {
+		return nil
+	}
+
+	// The value is present if any of the values nested inside it is.
+	n := len(d.present)
+	var err error
+	switch {
+	case d.isNestedStruct(fv.Type()):
+		// If the field is a struct, or a pointer to a struct, and
+		// there are values whose keys start with the field's key
+		// then decode those values into the struct's fields.
+		if fk == reflect.Ptr {
 			if fv.IsNil() {
 				fv.Set(reflect.New(fv.Type().Elem()))
 			}
-			if tu, ok := fv.Interface().(encoding.TextUnmarshaler); ok {
-				for _, s := range d.vals {
-					if err := tu.UnmarshalText([]byte(s)); err != nil {
-						return err
+			fv = fv.Elem()
+		}
+		err = d.decode(fv, key, path)
+	case fk == reflect.Slice:
+		// If the field is a slice and there are values whose keys
+		// consist of the field's key and an index then decode those
+		// values into the slice's elements.
+		err = d.decodeIndexed(fv, key, path, fo)
+	case fk == reflect.Map:
+		// If the field is a map and there are values whose keys start
+		// with the field's key then decode those values into the map.
+		err = d.decodeMap(fv, key, path, fo)
+	}
+	if len(d.present) > n {
+		d.present[path] = true
+	}
+	return err
+}
+
+// holdsText reports whether the values of type t are decoded from the
+// values of their own key, rather than from the values nested inside it,
+// i.e. t is a scalar type or a slice of a scalar type.
+func (d *Decoder) holdsText(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && !d.isScalar(t) {
+		t = t.Elem()
+	}
+	return d.isScalar(t)
+}
+
+// isScalar reports whether a value of type t is decoded from a single
+// string, i.e. t has a registered converter, is a time value, implements
+// encoding.TextUnmarshaler or FormUnmarshaler, or is a basic type, or t
+// is a pointer to such a type.
+func (d *Decoder) isScalar(t reflect.Type) bool {
+	if d.hasConverter(t) || isTimeType(t) {
+		return true
+	}
+	if hasMethods(t) {
+		for _, it := range []reflect.Type{textUnmarshalerType, formUnmarshalerType} {
+			if t.Implements(it) || (t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(it)) {
+				return true
+			}
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// decodeStrings decodes the given values of the key into the dst value.
+func (d *Decoder) decodeStrings(dst reflect.Value, key, path string, vals []string, fo fieldOptions) error {
+	fv := dst
+	fk := fv.Kind()
+	ln := len(vals)
+
+	// If the value implements FormUnmarshaler, call
+	// its UnmarshalForm method with all of the values.
+	if d.isUnmarshaler(fv) {
+		d.markDone(key)
+		fu := interfaceOf(fv, formUnmarshalerType).(FormUnmarshaler)
+		if err := fu.UnmarshalForm(vals); err != nil {
+			return d.valueError(key, vals[0], 0, path, fv.Type(), err)
+		}
+		return nil
+	}
+
+	// If the value implements encoding.TextUnmarshaler, loop over
+	// the values and call its UnmarshalText method with each value.
+	pv := fv
+	if fk != reflect.Ptr && pv.CanAddr() && pv.Type().Name() != "" {
+		pv = pv.Addr()
+	}
+	if pv.IsValid() && pv.Type().NumMethod() > 0 && !isTimeType(fv.Type()) && !d.hasConverter(fv.Type()) {
+		if pv.IsNil() {
+			pv.Set(reflect.New(pv.Type().Elem()))
+		}
+		if tu, ok := pv.Interface().(encoding.TextUnmarshaler); ok {
+			for j, s := range vals {
+				if s == "" && fo.empty != EmptySkip {
+					if err := setEmpty(fv, fo.empty); err != nil {
+						d.markDone(key)
+						return d.valueError(key, s, j, path, fv.Type(), err)
 					}
+					continue
+				}
+				if err := tu.UnmarshalText([]byte(s)); err != nil {
+					d.markDone(key)
+					return d.valueError(key, s, j, path, fv.Type(), err)
 				}
-				d.done[d.key] = true
-				continue
 			}
+			d.markDone(key)
+			return nil
 		}
+	}
 
-		// If the field is a slice, allocate a new slice with length
-		// equal to the number of elements in values, loop over the
-		// values and decode each one into its respective position.
-		if fv.Kind() == reflect.Slice {
-			sl := reflect.MakeSlice(fv.Type(), ln, ln)
-			for j := 0; j < ln; j++ {
-				if err := decodeString(sl.Index(j), d.vals[j]); err != nil {
-					return &ValueError{Key: key, Value: d.vals[j], Type: fk.String()}
+	// If the field is a slice, allocate a new slice with length
+	// equal to the number of elements in values, loop over the
+	// values and decode each one into its respective position.
+	if fk == reflect.Slice {
+		sl := reflect.MakeSlice(fv.Type(), ln, ln)
+		for j := 0; j < ln; j++ {
+			if err := d.decodeText(sl.Index(j), vals[j], fo); err != nil {
+				epath := path + "[" + strconv.Itoa(j) + "]"
+				if err := d.valueError(key, vals[j], j, epath, sl.Type().Elem(), err); err != nil {
+					return err
 				}
 			}
-			fv.Set(sl)
-			d.done[key] = true
-			continue
 		}
+		fv.Set(sl)
+		d.markDone(key)
+		return nil
+	}
 
-		if err := decodeString(fv, d.vals[0]); err != nil {
-			return &ValueError{Key: key, Value: d.vals[0], Type: fk.String()}
+	d.markDone(key)
+	if err := d.decodeText(fv, vals[0], fo); err != nil {
+		return d.valueError(key, vals[0], 0, path, fv.Type(), err)
+	}
+	return nil
+}
+
+// splitDefault splits the default value specified by a tag into the "|"
+// separated values if the type t is a slice, other than []byte.
+func splitDefault(t reflect.Type, def string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return strings.Split(def, "|")
+	}
+	return []string{def}
+}
+
+// valueError returns a new ValueError, or adds it to the collected errors
+// if the Decoder collects errors, in which case valueError returns nil.
+func (d *Decoder) valueError(key, val string, index int, path string, typ reflect.Type, err error) error {
+	return d.fieldError(&ValueError{Key: key, Value: val, Index: index, Field: path, Type: typ, Err: err})
+}
+
+// joinPath returns the Go path of a struct field by joining its
+// name to the path of the parent struct, e.g. "Address.Street".
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// decodeMap decodes the values nested inside the given key into the map
+// value dst, the segment that follows the key becomes the map key, e.g.
+// "meta.color=red" is decoded as meta["color"] = "red". If the key is empty
+// then dst is a top-level map and, unless the map's element type is itself
+// nested, every key in the Decoder's src is used as a map key as is. The
+// keys with segments beyond the map key, e.g. "meta.color.dark", are ignored
+// unless the map's elements can hold nested values.
+func (d *Decoder) decodeMap(dst reflect.Value, key, path string, fo fieldOptions) error {
+	var (
+		mtype  = dst.Type()
+		nested = d.isNestedStruct(mtype.Elem()) || mtype.Elem().Kind() == reflect.Map
+		deep   = nested || d.holdsNested(mtype.Elem())
+		prefix = d.style.prefix(key)
+		seen   = make(map[string]bool)
+		segs   = []string{}
+	)
+	keys := d.keysWithPrefix("")
+	if key != "" {
+		keys = d.keysWithPrefix(prefix)
+	}
+	for _, k := range keys {
+		var seg string
+		if key == "" {
+			if seg = k; nested {
+				seg = d.style.head(k)
+			}
+		} else {
+			var ok bool
+			if seg, ok = d.style.segment(k, prefix); !ok {
+				continue
+			}
+			if rel, _ := d.style.relative(k, prefix); !deep && rel != seg && rel != d.style.multi(seg) {
+				continue
+			}
+		}
+		if !seen[seg] {
+			seen[seg] = true
+			segs = append(segs, seg)
 		}
-		d.done[key] = true
 	}
+	if len(segs) == 0 {
+		return nil
+	}
+	sort.Strings(segs)
+
+	for _, seg := range segs {
+		ekey := d.style.join(key, seg)
+		epath := path + "[" + strconv.Quote(seg) + "]"
+		mk := reflect.New(mtype.Key()).Elem()
+		if err := decodeString(mk, seg); err != nil {
+			if err := d.valueError(ekey, seg, 0, epath, mtype.Key(), err); err != nil {
+				return err
+			}
+			continue
+		}
 
-	// Loop over all of the embedded struct values, if there were any, and decode them.
-	for _, v := range embedded {
-		if err := d.decode(v); err != nil {
+		ev := reflect.New(mtype.Elem()).Elem()
+		if old := dst.MapIndex(mk); old.IsValid() {
+			ev.Set(old)
+		}
+		if err := d.decodeValue(ev, ekey, epath, fo); err != nil {
 			return err
 		}
+		// Insert only the elements into which a value was stored.
+		if d.present[epath] {
+			if dst.IsNil() {
+				dst.Set(reflect.MakeMapWithSize(mtype, len(segs)))
+			}
+			dst.SetMapIndex(mk, ev)
+		}
 	}
+	return nil
+}
+
+// holdsNested reports whether the values of type t, other than nested
+// structs, can be decoded from the values nested inside their key, i.e.
+// t is a slice, a map, or a FormValuesUnmarshaler, or a pointer to one.
+func (d *Decoder) holdsNested(t reflect.Type) bool {
+	if implements(reflect.New(t).Elem(), formValuesUnmarshalerType) {
+		return true
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+}
+
+// decodeIndexed decodes the values whose keys consist of the given key and
+// an index, e.g. "items.0.sku" or "items[0][sku]", into the slice value dst.
+// The indexes determine the order of the elements in the slice, gaps
+// between the indexes are removed unless the Decoder disallows them.
+func (d *Decoder) decodeIndexed(dst reflect.Value, key, path string, fo fieldOptions) error {
+	var (
+		prefix  = d.style.prefix(key)
+		seen    = make(map[int]bool)
+		indexes = []int{}
+	)
+	for _, k := range d.keysWithPrefix(prefix) {
+		seg, ok := d.style.segment(k, prefix)
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || strconv.Itoa(i) != seg {
+			continue
+		}
+		if d.maxIndex >= 0 && i > d.maxIndex {
+			return &IndexError{Key: d.style.join(key, seg), Index: i, Max: d.maxIndex}
+		}
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	sort.Ints(indexes)
+	if d.strictSparse {
+		for j, i := range indexes {
+			if i != j {
+				return &IndexError{Key: d.style.join(key, strconv.Itoa(i)), Index: i, Max: -1}
+			}
+		}
+	}
+
+	ln := len(indexes)
+	sl := reflect.MakeSlice(dst.Type(), ln, ln)
+	for j, i := range indexes {
+		ekey := d.style.join(key, strconv.Itoa(i))
+		epath := path + "[" + strconv.Itoa(j) + "]"
+		ev := sl.Index(j)
+
+		if d.isValuesUnmarshaler(ev) {
+			if err := d.unmarshalFormValues(ev, ekey, epath); err != nil {
+				return err
+			}
+			continue
+		}
+		if d.isNestedStruct(ev.Type()) {
+			if ev.Kind() == reflect.Ptr {
+				ev.Set(reflect.New(ev.Type().Elem()))
+				ev = ev.Elem()
+			}
+			if err := d.decode(ev, ekey, epath); err != nil {
+				return err
+			}
+			continue
+		}
+		if d.holdsNested(ev.Type()) {
+			if err := d.decodeValue(ev, ekey, epath, fo); err != nil {
+				return err
+			}
+			continue
+		}
 
+		if vals := d.src[ekey]; len(vals) > 0 {
+			d.markDone(ekey)
+			d.present[epath] = true
+			if err := d.decodeText(ev, vals[0], fo); err != nil {
+				if err := d.valueError(ekey, vals[0], 0, epath, ev.Type(), err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	dst.Set(sl)
 	return nil
 }
 
+// markDone marks the given key, and its multi-valued
+// variant if any, as decoded.
+func (d *Decoder) markDone(key string) {
+	d.done[key] = true
+	if mkey := d.style.multi(key); mkey != "" {
+		d.done[mkey] = true
+	}
+}
+
+// unknownKeys returns the sorted list of the keys from the Decoder's
+// input that were not decoded into any of the destination's fields.
+func (d *Decoder) unknownKeys() []string {
+	keys := append([]string(nil), d.discarded...)
+	for k := range d.src {
+		if !d.done[k] {
+			keys = append(keys, k)
+		}
+	}
+	for k := range d.files {
+		if !d.done[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// values returns the values associated with the given key. If the
+// Decoder's path style has a marker for multi-valued keys, e.g. "tags[]",
+// the values associated with the marked key are included as well.
+func (d *Decoder) values(key string) []string {
+	vals := d.src[key]
+	if mkey := d.style.multi(key); mkey != "" {
+		if mvals := d.src[mkey]; len(mvals) > 0 {
+			vals = append(vals[:len(vals):len(vals)], mvals...)
+		}
+	}
+	return vals
+}
+
+// hasPrefix reports whether the Decoder's src contains a
+// key that belongs to a value nested inside the given key.
+func (d *Decoder) hasPrefix(key string) bool {
+	return len(d.keysWithPrefix(d.style.prefix(key))) > 0
+}
+
+// keysWithPrefix returns the sorted keys of the Decoder's src that start
+// with the given prefix. The keys are sorted once per src, so that finding
+// the values nested inside a key does not require scanning all the keys.
+func (d *Decoder) keysWithPrefix(prefix string) []string {
+	if d.keys == nil {
+		d.keys = make([]string, 0, len(d.src))
+		for k := range d.src {
+			d.keys = append(d.keys, k)
+		}
+		sort.Strings(d.keys)
+	}
+	i := sort.SearchStrings(d.keys, prefix)
+	n := sort.Search(len(d.keys)-i, func(j int) bool {
+		return !strings.HasPrefix(d.keys[i+j], prefix)
+	})
+	return d.keys[i : i+n]
+}
+
+var textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+
+// isNestedStruct reports whether the type t is a struct, or a pointer to
+// a struct, whose fields should be decoded individually, i.e. the type
+// has no registered converter and does not implement encoding.TextUnmarshaler.
+func (d *Decoder) isNestedStruct(t reflect.Type) bool {
+	if d.hasConverter(t) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	return !t.Implements(textUnmarshalerType) && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// An absentType describes how the fields of a nested struct
+// type are decoded if none of their keys is present.
+type absentType struct {
+	fill      bool // the fields must be decoded regardless
+	recursive bool // the type is nested inside itself
+}
+
+// absentType returns the absentType of the struct type t, or of the struct
+// type that t points to. The fields of the type must be decoded even if
+// none of their keys is present if some of them, or of the fields of the
+// structs nested inside t, have a default value or are required and the
+// Decoder validates the values.
+func (d *Decoder) absentType(t reflect.Type) absentType {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	at, ok := d.absentTypes[t]
+	if ok {
+		return at
+	}
+	at.fill = d.anyNestedField(t, make(map[reflect.Type]bool), func(f *field, ft reflect.Type) bool {
+		return f.hasDefault || (d.validate && f.opts.Contains("required"))
+	})
+	at.recursive = d.anyNestedField(t, make(map[reflect.Type]bool), func(f *field, ft reflect.Type) bool {
+		return ft == t || (ft.Kind() == reflect.Ptr && ft.Elem() == t)
+	})
+	if d.absentTypes == nil {
+		d.absentTypes = make(map[reflect.Type]absentType)
+	}
+	d.absentTypes[t] = at
+	return at
+}
+
+// anyNestedField reports whether fn returns true for any of the fields of
+// the struct type t, or of the structs nested inside t, and their types.
+// The seen map holds the struct types already visited.
+func (d *Decoder) anyNestedField(t reflect.Type, seen map[reflect.Type]bool, fn func(f *field, ft reflect.Type) bool) bool {
+	seen[t] = true
+	fields := cachedFields(t, d.tagKey)
+	for i := range fields {
+		f := &fields[i]
+		if f.ignored {
+			continue
+		}
+		ft := t.Field(f.index).Type
+		if fn(f, ft) {
+			return true
+		}
+		if d.isNestedStruct(ft) {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if !seen[ft] && d.anyNestedField(ft, seen, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeAbsent decodes the struct dst, or the struct that dst points to,
+// none of whose keys is present, so that its fields are set to their
+// defaults and validated. A nil
+// pointer is allocated, unless its struct type is recursive, in which case
+// the allocations would never end and the pointer is left as is.
+func (d *Decoder) decodeAbsent(dst reflect.Value, key, path string) error {
+	if dst.Kind() == reflect.Ptr {
+		if d.absentType(dst.Type()).recursive {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	return d.decode(dst, key, path)
+}
+
+// fieldOptions holds the options of a struct field's tag
+// that affect how the field's values are decoded.
+type fieldOptions struct {
+	empty  EmptyMode
+	layout string
+}
+
+// fieldOptions returns the decoding options of the
+// field f, whose Go path is the given path.
+func (d *Decoder) fieldOptions(f *field, path string) (fieldOptions, error) {
+	fo := fieldOptions{empty: d.empty, layout: f.layout}
+	if f.hasEmpty {
+		if !f.emptyValid {
+			return fo, fmt.Errorf("form: invalid empty option %q on field %s", f.emptyOpt, path)
+		}
+		fo.empty = f.empty
+	}
+	return fo, nil
+}
+
+// decodeText decodes the string src into the reflect.Value dst like
+// decodeString does, except that an empty src is handled according to
+// the empty mode of fo, values of the types with a registered converter
+// are converted by it, and time values are parsed using fo's layout.
+func (d *Decoder) decodeText(dst reflect.Value, src string, fo fieldOptions) error {
+	if len(src) == 0 {
+		return setEmpty(dst, fo.empty)
+	}
+	if ok, err := d.convert(dst, src); ok {
+		return err
+	}
+	if ok, err := d.decodeTime(dst, src, fo.layout); ok {
+		return err
+	}
+	return decodeString(dst, src)
+}
+
 // decodeString decodes the string src into the reflect.Value dst. If src
 // cannot be decoded into the dst value, decodeString will return an error.
 // If dst is not one of the supported kinds it will be ignored.
@@ -301,10 +1231,10 @@ func decodeString(dst reflect.Value, src string) error {
 	return nil
 }
 
-// structValueOf returns a new reflect.Value initialized to the concrete
-// struct value stored in the interface v. The ok return value reports
-// whether the value stored in v is a non-nil pointer to a struct or not.
-func structValueOf(v interface{}) (rv reflect.Value, ok bool) {
+// destValueOf returns a new reflect.Value initialized to the concrete
+// struct or map value stored in the interface v. The ok return value reports
+// whether the value stored in v is a non-nil pointer to a struct or a map.
+func destValueOf(v interface{}) (rv reflect.Value, ok bool) {
 	rv = reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return rv, false
@@ -313,15 +1243,16 @@ func structValueOf(v interface{}) (rv reflect.Value, ok bool) {
 	if rv.Kind() == reflect.Interface {
 		rv = rv.Elem()
 	}
-	if rv.Kind() != reflect.Struct {
+	if rv.Kind() != reflect.Struct && (rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String) {
 		return rv, false
 	}
 	return rv, true
 }
 
-// parseBytes parses the URL-encoded data and returns
-// a map listing the values specified for each key.
-func parseBytes(data []byte) (map[string][]string, error) {
+// parseBytes parses the URL-encoded data and returns a map listing
+// the values specified for each key. If the data exceeds one of the
+// given limits, parseBytes returns a LimitError.
+func parseBytes(data []byte, limits Limits) (map[string][]string, error) {
 	m := make(map[string][]string)
 	for len(data) != 0 {
 		pair := data
@@ -348,23 +1279,61 @@ func parseBytes(data []byte) (map[string][]string, error) {
 			return nil, err
 		}
 
+		if max := limits.MaxKeyLength; max > 0 && len(k) > max {
+			return nil, &LimitError{Limit: "MaxKeyLength", Max: int64(max), Value: int64(len(k)), Key: k}
+		}
+		if max := limits.MaxValues; max > 0 && len(m[k]) >= max {
+			return nil, &LimitError{Limit: "MaxValues", Max: int64(max), Value: int64(len(m[k]) + 1), Key: k}
+		}
+		if _, ok := m[k]; !ok {
+			if max := limits.MaxKeys; max > 0 && len(m) >= max {
+				return nil, &LimitError{Limit: "MaxKeys", Max: int64(max), Value: int64(len(m) + 1)}
+			}
+		}
+
 		m[k] = append(m[k], v)
 	}
 	return m, nil
 }
 
 func Marshal(v interface{}) ([]byte, error) {
-	var buf bytes.Buffer
-	if err := NewEncoder(&buf).Encode(v); err != nil {
+	e := &Encoder{tagKey: DefaultTagKey}
+	e.buf = getBuffer()
+	defer e.releaseBuffer()
+
+	if err := e.encode(v); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return append([]byte(nil), e.buf.Bytes()...), nil
 }
 
+// EncodeValues returns the URL-encoded values of v as url.Values.
+// It is the inverse of Transform.
+func EncodeValues(v interface{}) (url.Values, error) {
+	e := &Encoder{tagKey: DefaultTagKey, vals: url.Values{}}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.vals, nil
+}
+
+// An Encoder writes URL-encoded values to an output stream. An Encoder
+// can be reused for any number of calls to Encode, but it must not be
+// used concurrently.
 type Encoder struct {
-	tagKey string
-	out    string
-	w      io.Writer
+	tagKey     string
+	style      PathStyle
+	converters map[reflect.Type]func(reflect.Value) (string, error)
+	indexed    bool
+	canonical  bool
+	w          io.Writer
+
+	// the buffer holding the output of the current call to Encode
+	buf *bytes.Buffer
+	// the positions of the pairs in buf, in canonical mode
+	pairs []pair
+	// the values collected by EncodeValues
+	vals url.Values
 }
 
 func NewEncoder(w io.Writer) *Encoder {
@@ -376,10 +1345,47 @@ func (e *Encoder) WithTagKey(tagKey string) *Encoder {
 	return e
 }
 
+// WithPathStyle sets the syntax the Encoder uses for the keys of
+// nested and multi-valued values. The default style is PathDot.
+func (e *Encoder) WithPathStyle(style PathStyle) *Encoder {
+	e.style = style
+	return e
+}
+
+// IndexSlices causes the Encoder to encode the elements of all slices
+// under keys that consist of the slice's key and the element's index,
+// e.g. "tags.0=a&tags.1=b" or "tags[0]=a&tags[1]=b", instead of as the
+// multiple values of the slice's key. The elements of slices of structs,
+// maps and slices are always encoded under such keys.
+func (e *Encoder) IndexSlices() *Encoder {
+	e.indexed = true
+	return e
+}
+
+// Encode writes the URL-encoding of v to the Encoder's output stream.
+// The encoding is buffered and written with a single call to the
+// stream's Write method, if an error occurs nothing is written. The
+// output of each call to Encode is complete on its own, the outputs
+// of successive calls are not separated.
 func (e *Encoder) Encode(v interface{}) error {
+	e.buf = getBuffer()
+	defer e.releaseBuffer()
+
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encode encodes v into the Encoder's buffer.
+func (e *Encoder) encode(v interface{}) error {
 	if e.tagKey == "" {
 		e.tagKey = DefaultTagKey
 	}
+	e.pairs = e.pairs[:0]
 
 	rv := reflect.ValueOf(v)
 	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
@@ -390,108 +1396,307 @@ func (e *Encoder) Encode(v interface{}) error {
 	}
 
 	rt := rv.Type()
-	if rv.Kind() == reflect.Struct {
-		if err := e.encodeStruct(rv, rt); err != nil {
+	if m, ok := formMarshalerOf(rv).(FormValuesMarshaler); ok {
+		if err := e.marshalForm("", m); err != nil {
+			return err
+		}
+	} else if rv.Kind() == reflect.Struct {
+		if err := e.encodeStruct(rv, rt, ""); err != nil {
+			return err
+		}
+	} else if rv.Kind() == reflect.Map {
+		if err := e.encodeMap("", rv, ""); err != nil {
 			return err
 		}
 	}
-
-	if _, err := e.w.Write([]byte(e.out)); err != nil {
-		return err
+	if e.canonical {
+		e.sortPairs()
 	}
 	return nil
 }
 
 var textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
 
-func (e *Encoder) encodeStruct(rv reflect.Value, rt reflect.Type) error {
-	num := rt.NumField()
+func (e *Encoder) encodeStruct(rv reflect.Value, rt reflect.Type, prefix string) error {
+	fields := cachedFields(rt, e.tagKey)
 
-	for i := 0; i < num; i++ {
-		fv := rv.Field(i)
-		sf := rt.Field(i)
+	for i := range fields {
+		f := &fields[i]
+		fv := rv.Field(f.index)
 
-		// skip unexported fields
-		if sf.PkgPath != "" && !sf.Anonymous {
+		// get field info
+		if !fv.IsValid() || f.ignored || f.key == "-" || (f.omitempty && isEmptyValue(fv)) {
 			continue
 		}
 
-		// get field info
-		tag := sf.Tag.Get(e.tagKey)
-		key, opts := parseTag(tag)
-		if !fv.IsValid() || key == "-" || (opts.Contains("omitempty") && isEmptyValue(fv)) {
+		// encode embedded struct types
+		if f.anonymous && e.isNestedStruct(fv.Type()) {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := e.encodeStruct(fv, fv.Type(), prefix); err != nil {
+					return err
+				}
+			}
 			continue
 		}
-		if len(key) == 0 {
-			key = sf.Name
-		}
 
-		// implements encoding.TextMarshaler flag
-		var isTM bool
-		if fv.Type().Implements(textMarshalerType) {
-			isTM = true
+		if err := e.encodeValue(e.style.join(prefix, f.key), fv, f.layout); err != nil {
+			return err
 		}
+	}
 
-		// get the base elem value
-		for !isTM && (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) {
-			fv = fv.Elem()
-			if fv.IsValid() && fv.Type().Implements(textMarshalerType) {
-				isTM = true
-			}
+	return nil
+}
+
+// encodeValue encodes the value rv under the given key. Structs, maps and
+// slices are encoded as the values nested inside the key, time values are
+// formatted using the given layout.
+func (e *Encoder) encodeValue(key string, rv reflect.Value, layout string) error {
+	// encode values of the types with a registered converter
+	if val, ok, err := e.convert(rv); ok {
+		if err != nil {
+			return err
 		}
-		if !fv.IsValid() {
-			continue
+		e.add(key, val)
+		return nil
+	}
+
+	// encode values that implement FormMarshaler or FormValuesMarshaler
+	if m := formMarshalerOf(rv); m != nil {
+		return e.marshalForm(key, m)
+	}
+
+	// encode time values using the layout specified by the tag
+	if val, ok := encodeTime(rv, layout); ok {
+		e.add(key, val)
+		return nil
+	}
+
+	// get the base elem value, unless it implements encoding.TextMarshaler
+	for !implementsTextMarshaler(rv.Type()) && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return nil
 		}
+		rv = rv.Elem()
+	}
 
-		// handle marshaler
-		if isTM {
-			var val string
-			tm, ok := fv.Interface().(encoding.TextMarshaler)
-			if ok {
-				b, err := tm.MarshalText()
-				if err != nil {
-					return err
-				}
-				val = string(b)
-			}
-			if len(e.out) > 0 {
-				e.out += "&"
-			}
-			e.out += url.QueryEscape(key) + "=" + url.QueryEscape(val)
-			continue
+	// handle marshaler
+	if implementsTextMarshaler(rv.Type()) {
+		if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			return nil
+		}
+		b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
 		}
+		e.add(key, string(b))
+		return nil
+	}
 
-		// encode embedded struct types
-		if fv.Kind() == reflect.Struct && sf.Anonymous {
-			if err := e.encodeStruct(fv, fv.Type()); err != nil {
+	switch rv.Kind() {
+	case reflect.Struct:
+		return e.encodeStruct(rv, rv.Type(), key)
+	case reflect.Map:
+		return e.encodeMap(key, rv, layout)
+	case reflect.Slice, reflect.Array:
+		return e.encodeSlice(key, rv, layout)
+	}
+	e.add(key, encodeString(rv))
+	return nil
+}
+
+// encodeSlice encodes the elements of the slice or array rv under the
+// given key. The elements that are encoded as nested values, and all of
+// the elements if the Encoder indexes slices, are encoded under keys that
+// consist of the key and the element's index, e.g. "items.0.sku".
+func (e *Encoder) encodeSlice(key string, rv reflect.Value, layout string) error {
+	ln := rv.Len()
+	if e.indexed || e.isNested(rv.Type().Elem()) {
+		for j := 0; j < ln; j++ {
+			if err := e.encodeValue(e.style.join(key, strconv.Itoa(j)), rv.Index(j), layout); err != nil {
 				return err
 			}
-			continue
 		}
+		return nil
+	}
 
-		// encode slice values
-		if fv.Kind() == reflect.Slice {
-			ln := fv.Len()
-			for j := 0; j < ln; j++ {
-				val := encodeString(fv.Index(j))
-				if len(e.out) > 0 {
-					e.out += "&"
-				}
-				e.out += url.QueryEscape(key) + "=" + url.QueryEscape(val)
-			}
-			continue
+	if mkey := e.style.multi(key); mkey != "" {
+		key = mkey
+	}
+	for j := 0; j < ln; j++ {
+		if err := e.encodeValue(key, rv.Index(j), layout); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		val := encodeString(fv)
-		if len(e.out) > 0 {
-			e.out += "&"
+// encodeMap encodes the elements of the map rv under the keys that
+// consist of the given key and the element's map key, e.g. "meta.color",
+// in the order of the map keys. If the key is empty the map keys are
+// used as is. A map key that would be a segment of the keys, but that
+// contains the path style's separators, results in an error.
+func (e *Encoder) encodeMap(key string, rv reflect.Value, layout string) error {
+	et := rv.Type().Elem()
+	segment := key != "" || e.isNestedStruct(et) || et.Kind() == reflect.Map
+
+	mkeys := rv.MapKeys()
+	keys := make([]string, len(mkeys))
+	for i, mk := range mkeys {
+		keys[i] = encodeString(mk)
+		if segment && e.style.hasSeparator(keys[i]) {
+			return fmt.Errorf("form: map key %q contains a path separator", keys[i])
 		}
-		e.out += url.QueryEscape(key) + "=" + url.QueryEscape(val)
 	}
+	sort.Sort(mapKeys{keys, mkeys})
 
+	for i, mk := range mkeys {
+		if err := e.encodeValue(e.style.join(key, keys[i]), rv.MapIndex(mk), layout); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// mapKeys sorts the values of a map's keys by their string representations.
+type mapKeys struct {
+	strs []string
+	vals []reflect.Value
+}
+
+func (m mapKeys) Len() int           { return len(m.strs) }
+func (m mapKeys) Less(i, j int) bool { return m.strs[i] < m.strs[j] }
+func (m mapKeys) Swap(i, j int) {
+	m.strs[i], m.strs[j] = m.strs[j], m.strs[i]
+	m.vals[i], m.vals[j] = m.vals[j], m.vals[i]
+}
+
+// isNestedStruct reports whether the type t is a struct, or a pointer to
+// a struct, whose fields should be encoded individually, i.e. the type
+// has no registered converter and implements none of the marshalers.
+func (e *Encoder) isNestedStruct(t reflect.Type) bool {
+	for {
+		if _, ok := e.converters[t]; ok || isMarshalerType(t) || t == timeType {
+			return false
+		}
+		if t.Kind() != reflect.Ptr {
+			return t.Kind() == reflect.Struct
+		}
+		t = t.Elem()
+	}
+}
+
+// isNested reports whether the values of type t are encoded as values
+// nested inside their key, i.e. t is a struct whose fields are encoded
+// individually, a map, a slice, an array, or a FormValuesMarshaler.
+func (e *Encoder) isNested(t reflect.Type) bool {
+	if e.isNestedStruct(t) {
+		return true
+	}
+	for {
+		if _, ok := e.converters[t]; ok {
+			return false
+		}
+		if t.Implements(formValuesMarshalerType) || reflect.PtrTo(t).Implements(formValuesMarshalerType) {
+			return true
+		}
+		if isMarshalerType(t) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			return true
+		case reflect.Ptr:
+			t = t.Elem()
+			continue
+		}
+		return false
+	}
+}
+
+// implementsTextMarshaler reports whether the type t implements encoding.TextMarshaler.
+func implementsTextMarshaler(t reflect.Type) bool {
+	return hasMethods(t) && t.Implements(textMarshalerType)
+}
+
+// isMarshalerType reports whether the type t, or a pointer to t, implements
+// encoding.TextMarshaler, FormMarshaler or FormValuesMarshaler.
+func isMarshalerType(t reflect.Type) bool {
+	if !hasMethods(t) {
+		return false
+	}
+	for _, it := range []reflect.Type{textMarshalerType, formMarshalerType, formValuesMarshalerType} {
+		if t.Implements(it) || (t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(it)) {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends the key-value pair to the Encoder's buffer.
+func (e *Encoder) add(key, val string) {
+	if e.vals != nil {
+		e.vals[key] = append(e.vals[key], val)
+		return
+	}
+	if e.canonical {
+		e.addPair(key, val)
+		return
+	}
+	if e.buf.Len() > 0 {
+		e.buf.WriteByte('&')
+	}
+	writeQueryEscaped(e.buf, key, false)
+	e.buf.WriteByte('=')
+	writeQueryEscaped(e.buf, val, false)
+}
+
+// bufferPool holds the buffers used by the Encoders.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity above which a buffer
+// is not returned to the pool, so that it can be freed.
+const maxPooledBuffer = 64 << 10 // 64 KB
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// releaseBuffer returns the Encoder's buffer to the pool.
+func (e *Encoder) releaseBuffer() {
+	if e.buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(e.buf)
+	}
+	e.buf = nil
+}
+
+// writeQueryEscaped writes the string s, escaped as by url.QueryEscape,
+// to buf without allocating an intermediate string. If rfc3986 is true
+// spaces are escaped as "%20" instead of "+", as specified by RFC 3986.
+func writeQueryEscaped(buf *bytes.Buffer, s string, rfc3986 bool) {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == ' ' && !rfc3986:
+			buf.WriteByte('+')
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&15])
+		}
+	}
+}
+
 func encodeString(rv reflect.Value) string {
 	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
 		rv = rv.Elem()
@@ -528,4 +1733,4 @@ func isEmptyValue(rv reflect.Value) bool {
 		return rv.IsNil()
 	}
 	return false
-}
\ No newline at end of file
+}
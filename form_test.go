@@ -1,8 +1,12 @@
 package form
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -14,6 +18,10 @@ import (
 
 func boolp(b bool) *bool { return &b }
 
+func numError(fn, num string, err error) error {
+	return &strconv.NumError{Func: fn, Num: num, Err: err}
+}
+
 type boolType struct {
 	Bool   bool
 	Boolp  *bool
@@ -406,6 +414,61 @@ const embedValStringMultipart = `
 --foobar--
 `
 
+type nestedType struct {
+	Name    string         `form:"name"`
+	Address nestedAddress  `form:"address"`
+	Billing *nestedAddress `form:"billing"`
+	Other   *nestedAddress `form:"other"`
+}
+
+type nestedAddress struct {
+	Street string    `form:"street"`
+	City   string    `form:"city"`
+	Geo    nestedGeo `form:"geo"`
+}
+
+type nestedGeo struct {
+	Lat float64 `form:"lat"`
+	Lng float64 `form:"lng"`
+}
+
+var nestedVal = nestedType{
+	Name: "foo",
+	Address: nestedAddress{
+		Street: "Main St. 1",
+		City:   "Springfield",
+		Geo:    nestedGeo{Lat: 44.05, Lng: -123.09},
+	},
+	Billing: &nestedAddress{
+		City: "Shelbyville",
+	},
+}
+
+var nestedValues = url.Values{"name": {"foo"}, "address.street": {"Main St. 1"}, "address.city": {"Springfield"},
+	"address.geo.lat": {"44.05"}, "address.geo.lng": {"-123.09"}, "billing.city": {"Shelbyville"}}
+
+const nestedValString = `name=foo&address.street=Main+St.+1&address.city=Springfield&address.geo.lat=44.05&address.geo.lng=-123.09&billing.city=Shelbyville`
+
+type mapType struct {
+	Meta   map[string]string         `form:"meta"`
+	Tags   map[string][]string       `form:"tags"`
+	Counts map[string]int            `form:"counts"`
+	Users  map[string]nestedGeo      `form:"users"`
+	ByID   map[int]string            `form:"by_id"`
+	Nested map[string]map[string]int `form:"nested"`
+}
+
+var mapVal = mapType{
+	Meta:   map[string]string{"color": "red", "size": "xl"},
+	Tags:   map[string][]string{"a": {"x", "y"}},
+	Counts: map[string]int{"foo": 1, "bar": 2},
+	Users:  map[string]nestedGeo{"joe": {Lat: 1.5, Lng: 2.5}},
+	ByID:   map[int]string{7: "seven"},
+	Nested: map[string]map[string]int{"a": {"b": 3}},
+}
+
+const mapValString = `meta.color=red&meta.size=xl&tags.a=x&tags.a=y&counts.foo=1&counts.bar=2&users.joe.lat=1.5&users.joe.lng=2.5&by_id.7=seven&nested.a.b=3`
+
 type marshalSlice []string
 
 func (s *marshalSlice) MarshalText() ([]byte, error) {
@@ -630,6 +693,40 @@ func TestUnmarshal(t *testing.T) {
 		data: ifaceValString,
 		dst:  &ifaceType{},
 		want: &ifaceVal,
+	}, {
+		name: "nested structs",
+		data: nestedValString,
+		dst:  &nestedType{},
+		want: &nestedVal,
+	}, {
+		name: "map fields",
+		data: mapValString,
+		dst:  &mapType{},
+		want: &mapVal,
+	}, {
+		name: "map fields with deeper keys",
+		data: "meta.a.b=1&meta.c=2&counts.x.y=3&tags.a.0=x&nested.a.b=3",
+		dst:  &mapType{},
+		want: &mapType{
+			Meta:   map[string]string{"c": "2"},
+			Tags:   map[string][]string{"a": {"x"}},
+			Nested: map[string]map[string]int{"a": {"b": 3}},
+		},
+	}, {
+		name: "top-level map",
+		data: "a=1&b=2&b=3&c.d=4",
+		dst:  &map[string]string{},
+		want: &map[string]string{"a": "1", "b": "2", "c.d": "4"},
+	}, {
+		name: "top-level map of slices",
+		data: "a=1&b=2&b=3",
+		dst:  &map[string][]string{},
+		want: &map[string][]string{"a": {"1"}, "b": {"2", "3"}},
+	}, {
+		name: "top-level map of structs",
+		data: "joe.lat=1&joe.lng=2&ann.lat=3",
+		dst:  &map[string]nestedGeo{},
+		want: &map[string]nestedGeo{"joe": {Lat: 1, Lng: 2}, "ann": {Lat: 3}},
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -642,6 +739,61 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+type mapPtrType struct {
+	M *map[string]string     `form:"m"`
+	X map[string]interface{} `form:"x"`
+}
+
+func TestDecoderMapElements(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		dst     interface{}
+		want    interface{}
+		present map[string]bool
+		unknown []string
+	}{{
+		name:    "pointer to map",
+		data:    "m.a=1",
+		dst:     &mapPtrType{},
+		want:    &mapPtrType{M: &map[string]string{"a": "1"}},
+		present: map[string]bool{"M": true, "X": false},
+	}, {
+		name:    "elements that can't hold the values",
+		data:    "x.a=1&m=2",
+		dst:     &mapPtrType{},
+		want:    &mapPtrType{},
+		present: map[string]bool{"M": false, "X": false},
+		unknown: []string{"m", "x.a"},
+	}, {
+		name:    "nested map elements",
+		data:    "a.b=1&d=3",
+		dst:     &map[string]map[string]string{},
+		want:    &map[string]map[string]string{"a": {"b": "1"}},
+		unknown: []string{"d"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data)).DisallowUnknownFields()
+			err := d.Decode(tt.dst)
+			if tt.unknown == nil && err != nil {
+				t.Fatalf("got error %v", err)
+			} else if want := (&UnknownKeysError{Keys: tt.unknown}); tt.unknown != nil && !reflect.DeepEqual(err, want) {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.dst, tt.want)
+			}
+			for path, want := range tt.present {
+				if got := d.Present(path); got != want {
+					t.Errorf("Present(%q) got %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestTransform(t *testing.T) {
 	tests := []struct {
 		name string
@@ -724,6 +876,16 @@ func TestTransform(t *testing.T) {
 		vals: ifaceValues,
 		dst:  &ifaceType{},
 		want: &ifaceVal,
+	}, {
+		name: "nested structs",
+		vals: nestedValues,
+		dst:  &nestedType{},
+		want: &nestedVal,
+	}, {
+		name: "top-level map",
+		vals: url.Values{"a": {"1"}, "b": {"2"}},
+		dst:  &map[string]int{},
+		want: &map[string]int{"a": 1, "b": 2},
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -763,62 +925,91 @@ func TestUnmarshal_errors(t *testing.T) {
 		name: "bool type err",
 		val:  "Bool=Hello World",
 		dst:  &boolType{},
-		err:  &ValueError{Key: "Bool", Value: "Hello World", Type: "bool"},
+		err:  &ValueError{Key: "Bool", Value: "Hello World", Field: "Bool", Type: reflect.TypeOf(false), Err: numError("ParseBool", "Hello World", strconv.ErrSyntax)},
 	}, {
 		name: "int type err",
 		val:  "Int=Hello World",
 		dst:  &intType{},
-		err:  &ValueError{Key: "Int", Value: "Hello World", Type: "int"},
+		err:  &ValueError{Key: "Int", Value: "Hello World", Field: "Int", Type: reflect.TypeOf(0), Err: numError("ParseInt", "Hello World", strconv.ErrSyntax)},
 	}, {
 		name: "int8 value out of range",
 		val:  "Int8=128",
 		dst:  &intType{},
-		err:  &ValueError{Key: "Int8", Value: "128", Type: "int8"},
+		err:  &ValueError{Key: "Int8", Value: "128", Field: "Int8", Type: reflect.TypeOf(int8(0)), Err: numError("ParseInt", "128", strconv.ErrRange)},
 	}, {
 		name: "int16 value out of range",
 		val:  "Int16=32768",
 		dst:  &intType{},
-		err:  &ValueError{Key: "Int16", Value: "32768", Type: "int16"},
+		err:  &ValueError{Key: "Int16", Value: "32768", Field: "Int16", Type: reflect.TypeOf(int16(0)), Err: numError("ParseInt", "32768", strconv.ErrRange)},
 	}, {
 		name: "int32 value out of range",
 		val:  "Int32=2147483648",
 		dst:  &intType{},
-		err:  &ValueError{Key: "Int32", Value: "2147483648", Type: "int32"},
+		err:  &ValueError{Key: "Int32", Value: "2147483648", Field: "Int32", Type: reflect.TypeOf(int32(0)), Err: numError("ParseInt", "2147483648", strconv.ErrRange)},
 	}, {
 		name: "int64 value out of range",
 		val:  "Int64=9223372036854775808",
 		dst:  &intType{},
-		err:  &ValueError{Key: "Int64", Value: "9223372036854775808", Type: "int64"},
+		err:  &ValueError{Key: "Int64", Value: "9223372036854775808", Field: "Int64", Type: reflect.TypeOf(int64(0)), Err: numError("ParseInt", "9223372036854775808", strconv.ErrRange)},
 	}, {
 		name: "uint type err",
 		val:  "Uint=Hello World",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint", Value: "Hello World", Type: "uint"},
+		err:  &ValueError{Key: "Uint", Value: "Hello World", Field: "Uint", Type: reflect.TypeOf(uint(0)), Err: numError("ParseUint", "Hello World", strconv.ErrSyntax)},
 	}, {
 		name: "uint value out of range",
 		val:  "Uint=-128",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint", Value: "-128", Type: "uint"},
+		err:  &ValueError{Key: "Uint", Value: "-128", Field: "Uint", Type: reflect.TypeOf(uint(0)), Err: numError("ParseUint", "-128", strconv.ErrSyntax)},
 	}, {
 		name: "uint8 value out of range",
 		val:  "Uint8=256",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint8", Value: "256", Type: "uint8"},
+		err:  &ValueError{Key: "Uint8", Value: "256", Field: "Uint8", Type: reflect.TypeOf(uint8(0)), Err: numError("ParseUint", "256", strconv.ErrRange)},
 	}, {
 		name: "uint16 value out of range",
 		val:  "Uint16=65536",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint16", Value: "65536", Type: "uint16"},
+		err:  &ValueError{Key: "Uint16", Value: "65536", Field: "Uint16", Type: reflect.TypeOf(uint16(0)), Err: numError("ParseUint", "65536", strconv.ErrRange)},
 	}, {
 		name: "uint32 value out of range",
 		val:  "Uint32=4294967296",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint32", Value: "4294967296", Type: "uint32"},
+		err:  &ValueError{Key: "Uint32", Value: "4294967296", Field: "Uint32", Type: reflect.TypeOf(uint32(0)), Err: numError("ParseUint", "4294967296", strconv.ErrRange)},
 	}, {
 		name: "uint64 value out of range",
 		val:  "Uint64=18446744073709551616",
 		dst:  &uintType{},
-		err:  &ValueError{Key: "Uint64", Value: "18446744073709551616", Type: "uint64"},
+		err:  &ValueError{Key: "Uint64", Value: "18446744073709551616", Field: "Uint64", Type: reflect.TypeOf(uint64(0)), Err: numError("ParseUint", "18446744073709551616", strconv.ErrRange)},
+	}, {
+		name: "int pointer type err",
+		val:  "Intp=abc",
+		dst:  &intpType{},
+		err:  &ValueError{Key: "Intp", Value: "abc", Field: "Intp", Type: reflect.TypeOf(intp(0)), Err: numError("ParseInt", "abc", strconv.ErrSyntax)},
+	}, {
+		name: "int pointer slice type err",
+		val:  "Int8ps=1&Int8ps=2&Int8ps=300",
+		dst:  &intpsType{},
+		err:  &ValueError{Key: "Int8ps", Value: "300", Index: 2, Field: "Int8ps[2]", Type: reflect.TypeOf(i8p(0)), Err: numError("ParseInt", "300", strconv.ErrRange)},
+	}, {
+		name: "map with non-string keys should return error",
+		dst:  &map[int]string{},
+		err:  &ArgumentError{reflect.TypeOf(&map[int]string{})},
+	}, {
+		name: "map key type err",
+		val:  "by_id.x=seven",
+		dst:  &mapType{},
+		err:  &ValueError{Key: "by_id.x", Value: "x", Field: `ByID["x"]`, Type: reflect.TypeOf(0), Err: numError("ParseInt", "x", strconv.ErrSyntax)},
+	}, {
+		name: "map value type err",
+		val:  "counts.foo=bar",
+		dst:  &mapType{},
+		err:  &ValueError{Key: "counts.foo", Value: "bar", Field: `Counts["foo"]`, Type: reflect.TypeOf(0), Err: numError("ParseInt", "bar", strconv.ErrSyntax)},
+	}, {
+		name: "nested field type err",
+		val:  "address.geo.lat=north",
+		dst:  &nestedType{},
+		err:  &ValueError{Key: "address.geo.lat", Value: "north", Field: "Address.Geo.Lat", Type: reflect.TypeOf(0.0), Err: numError("ParseFloat", "north", strconv.ErrSyntax)},
 	}}
 
 	for _, tt := range tests {
@@ -858,7 +1049,7 @@ func TestParseBytes(t *testing.T) {
 	}
 
 	for i, tt := range tests {
-		got, err := parseBytes([]byte(tt.in))
+		got, err := parseBytes([]byte(tt.in), Limits{})
 		if !reflect.DeepEqual(err, tt.err) {
 			t.Errorf("#%d: got err %v, want %v", i, err, tt.err)
 		}
@@ -867,3 +1058,753 @@ func TestParseBytes(t *testing.T) {
 		}
 	}
 }
+
+type bracketType struct {
+	User  bracketUser   `form:"user"`
+	Items []bracketItem `form:"items"`
+}
+
+type bracketUser struct {
+	Name   string   `form:"name"`
+	Emails []string `form:"emails"`
+}
+
+type bracketItem struct {
+	SKU int  `form:"sku"`
+	Qty *int `form:"qty"`
+}
+
+func TestDecoderPathStyle(t *testing.T) {
+	tests := []struct {
+		name  string
+		style PathStyle
+		data  string
+		dst   interface{}
+		want  interface{}
+		err   error
+	}{{
+		name:  "bracket nested struct",
+		style: PathBracket,
+		data:  "user[name]=x&user[emails][]=a&user[emails][]=b",
+		dst:   &bracketType{},
+		want:  &bracketType{User: bracketUser{Name: "x", Emails: []string{"a", "b"}}},
+	}, {
+		name:  "bracket slice without marker",
+		style: PathBracket,
+		data:  "user[emails]=a&user[emails]=b",
+		dst:   &bracketType{},
+		want:  &bracketType{User: bracketUser{Emails: []string{"a", "b"}}},
+	}, {
+		name:  "bracket indexed slice of structs",
+		style: PathBracket,
+		data:  "items[0][sku]=1&items[1][sku]=2&items[1][qty]=5&items[0][qty]=3",
+		dst:   &bracketType{},
+		want:  &bracketType{Items: []bracketItem{{SKU: 1, Qty: intp(3)}, {SKU: 2, Qty: intp(5)}}},
+	}, {
+		name:  "bracket keys ignored by dot style",
+		style: PathDot,
+		data:  "user[name]=x&items[0][sku]=1",
+		dst:   &bracketType{},
+		want:  &bracketType{},
+	}, {
+		name:  "bracket map fields",
+		style: PathBracket,
+		data:  "meta[color]=red&tags[a][]=x&tags[a][]=y&users[joe][lat]=1.5",
+		dst:   &mapType{},
+		want: &mapType{
+			Meta:  map[string]string{"color": "red"},
+			Tags:  map[string][]string{"a": {"x", "y"}},
+			Users: map[string]nestedGeo{"joe": {Lat: 1.5}},
+		},
+	}, {
+		name:  "bracket top-level map of structs",
+		style: PathBracket,
+		data:  "joe[lat]=1&joe[lng]=2",
+		dst:   &map[string]nestedGeo{},
+		want:  &map[string]nestedGeo{"joe": {Lat: 1, Lng: 2}},
+	}, {
+		name:  "bracket value error",
+		style: PathBracket,
+		data:  "items[0][sku]=abc",
+		dst:   &bracketType{},
+		want:  &bracketType{},
+		err:   &ValueError{Key: "items[0][sku]", Value: "abc", Field: "Items[0].SKU", Type: reflect.TypeOf(0), Err: numError("ParseInt", "abc", strconv.ErrSyntax)},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data)).WithPathStyle(tt.style)
+			if err := d.Decode(tt.dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %#v, want %#v", err, tt.err)
+			} else if err == nil && !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderPathStyle(t *testing.T) {
+	var buf strings.Builder
+	if err := NewEncoder(&buf).WithPathStyle(PathBracket).Encode(stringVal); err != nil {
+		t.Fatal(err)
+	}
+	want := `String=51&Stringp=foo&Strings%5B%5D=foo&Strings%5B%5D=bar&Strings%5B%5D=baz&Stringps%5B%5D=baz&Stringps%5B%5D=bar&Stringps%5B%5D=foo`
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	dst := &stringType{}
+	if err := NewDecoder(strings.NewReader(want)).WithPathStyle(PathBracket).Decode(dst); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(dst, &stringVal) {
+		t.Errorf("got %+v, want %+v", dst, stringVal)
+	}
+}
+
+type indexedType struct {
+	Items   []bracketItem        `form:"items"`
+	Ptrs    []*bracketItem       `form:"ptrs"`
+	Tags    []string             `form:"tags"`
+	ItemPtr *[]bracketItem       `form:"s"`
+	Metas   []*map[string]string `form:"metas"`
+	MetaPtr *map[string]int      `form:"m"`
+}
+
+func TestEncoderNested(t *testing.T) {
+	qty := 2
+	tests := []struct {
+		name    string
+		style   PathStyle
+		indexed bool
+		src     interface{}
+		want    string
+	}{{
+		name: "nested structs",
+		src:  &nestedType{Name: "foo", Billing: &nestedAddress{City: "x", Geo: nestedGeo{Lat: 1}}},
+		want: "name=foo&address.street=&address.city=&address.geo.lat=0&address.geo.lng=0" +
+			"&billing.street=&billing.city=x&billing.geo.lat=1&billing.geo.lng=0",
+	}, {
+		name:  "slices of structs",
+		style: PathBracket,
+		src: &bracketType{User: bracketUser{Name: "joe", Emails: []string{"a", "b"}},
+			Items: []bracketItem{{SKU: 1}, {SKU: 2, Qty: &qty}}},
+		want: "user%5Bname%5D=joe&user%5Bemails%5D%5B%5D=a&user%5Bemails%5D%5B%5D=b" +
+			"&items%5B0%5D%5Bsku%5D=1&items%5B1%5D%5Bsku%5D=2&items%5B1%5D%5Bqty%5D=2",
+	}, {
+		name:    "indexed slices",
+		indexed: true,
+		src:     &bracketType{User: bracketUser{Emails: []string{"a", "b"}}},
+		want:    "user.name=&user.emails.0=a&user.emails.1=b",
+	}, {
+		name: "maps",
+		src:  &mapType{Counts: map[string]int{"foo": 1, "bar": 2}, Users: map[string]nestedGeo{"joe": {Lat: 1.5}}},
+		want: "counts.bar=2&counts.foo=1&users.joe.lat=1.5&users.joe.lng=0",
+	}, {
+		name: "top-level map",
+		src:  map[string][]string{"b": {"1", "2"}, "a": {"3"}},
+		want: "a=3&b=1&b=2",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			e := NewEncoder(&b).WithPathStyle(tt.style)
+			if tt.indexed {
+				e.IndexSlices()
+			}
+			if err := e.Encode(tt.src); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderMapKeySeparators(t *testing.T) {
+	tests := []struct {
+		style PathStyle
+		src   interface{}
+		err   bool
+	}{
+		{PathDot, &mapType{Meta: map[string]string{"example.com": "1"}}, true},
+		{PathDot, &mapType{Meta: map[string]string{"a[0]": "1"}}, false},
+		{PathBracket, &mapType{Meta: map[string]string{"a[0]": "1"}}, true},
+		{PathBracket, &mapType{Meta: map[string]string{"example.com": "1"}}, false},
+		{PathDot, &map[string]nestedGeo{"a.b": {}}, true},
+		{PathDot, &map[string]string{"a.b": "1"}, false},
+	}
+
+	for i, tt := range tests {
+		err := NewEncoder(ioutil.Discard).WithPathStyle(tt.style).Encode(tt.src)
+		if (err != nil) != tt.err {
+			t.Errorf("#%d: got error %v, want error %t", i, err, tt.err)
+		}
+	}
+}
+
+type nestedSlicesType struct {
+	Rows []map[string]string `form:"rows"`
+	Grid [][]string          `form:"grid"`
+}
+
+func TestEncoderNestedRoundTrip(t *testing.T) {
+	qty := 2
+	tests := []struct {
+		name string
+		src  interface{}
+	}{
+		{"nested structs", &nestedVal},
+		{"maps", &mapVal},
+		{"slices of structs", &bracketType{User: bracketUser{Name: "joe", Emails: []string{"a", "b"}},
+			Items: []bracketItem{{SKU: 1}, {SKU: 2, Qty: &qty}}}},
+		{"top-level map", &map[string]nestedGeo{"a": {Lat: 1}, "b": {Lng: 2}}},
+		{"slices of maps and slices", &nestedSlicesType{
+			Rows: []map[string]string{{"a": "1"}, {"b": "2", "c": "3"}},
+			Grid: [][]string{{"a", "b"}, {"c"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, style := range []PathStyle{PathDot, PathBracket} {
+			for _, indexed := range []bool{false, true} {
+				var b strings.Builder
+				e := NewEncoder(&b).WithPathStyle(style)
+				if indexed {
+					e.IndexSlices()
+				}
+				if err := e.Encode(tt.src); err != nil {
+					t.Fatal(err)
+				}
+
+				dst := reflect.New(reflect.TypeOf(tt.src).Elem())
+				d := NewDecoder(strings.NewReader(b.String())).WithPathStyle(style).DisallowUnknownFields()
+				if err := d.Decode(dst.Interface()); err != nil {
+					t.Errorf("%s (style %d, indexed %t): %v", tt.name, style, indexed, err)
+				} else if !reflect.DeepEqual(dst.Interface(), tt.src) {
+					t.Errorf("%s (style %d, indexed %t): got %+v, want %+v",
+						tt.name, style, indexed, dst.Elem(), reflect.ValueOf(tt.src).Elem())
+				}
+			}
+		}
+	}
+}
+
+func TestDecoderIndexes(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		max        int
+		strict     bool
+		strictKeys bool
+		want       interface{}
+		err        error
+	}{{
+		name: "dotted indexed slice of structs",
+		data: "items.0.qty=2&items.1.qty=5&items.1.sku=7",
+		max:  defaultMaxIndex,
+		want: &indexedType{Items: []bracketItem{{Qty: intp(2)}, {SKU: 7, Qty: intp(5)}}},
+	}, {
+		name: "indexed slice of struct pointers",
+		data: "ptrs.0.sku=1&ptrs.1.sku=2",
+		max:  defaultMaxIndex,
+		want: &indexedType{Ptrs: []*bracketItem{{SKU: 1}, {SKU: 2}}},
+	}, {
+		name: "indexed slice of strings",
+		data: "tags.1=b&tags.0=a",
+		max:  defaultMaxIndex,
+		want: &indexedType{Tags: []string{"a", "b"}},
+	}, {
+		name: "sparse indexes are compacted",
+		data: "items.3.sku=3&items.10.sku=10&items.7.sku=7",
+		max:  defaultMaxIndex,
+		want: &indexedType{Items: []bracketItem{{SKU: 3}, {SKU: 7}, {SKU: 10}}},
+	}, {
+		name:   "sparse indexes are rejected",
+		data:   "items.0.sku=0&items.2.sku=2",
+		max:    defaultMaxIndex,
+		strict: true,
+		err:    &IndexError{Key: "items.2", Index: 2, Max: -1},
+	}, {
+		name:   "contiguous indexes with strict option",
+		data:   "items.1.sku=1&items.0.sku=0",
+		max:    defaultMaxIndex,
+		strict: true,
+		want:   &indexedType{Items: []bracketItem{{SKU: 0}, {SKU: 1}}},
+	}, {
+		name: "index exceeds maximum",
+		data: "items.999999999.sku=1",
+		max:  defaultMaxIndex,
+		err:  &IndexError{Key: "items.999999999", Index: 999999999, Max: defaultMaxIndex},
+	}, {
+		name: "custom maximum",
+		data: "tags.0=a&tags.3=b",
+		max:  2,
+		err:  &IndexError{Key: "tags.3", Index: 3, Max: 2},
+	}, {
+		name: "no maximum",
+		data: "tags.999999999=a",
+		max:  -1,
+		want: &indexedType{Tags: []string{"a"}},
+	}, {
+		name: "non-canonical indexes are ignored",
+		data: "tags.01=a&tags.-1=b&tags.+2=c",
+		max:  defaultMaxIndex,
+		want: &indexedType{},
+	}, {
+		name:       "pointer to an indexed slice",
+		data:       "s.0.sku=1&s.1.qty=2",
+		max:        defaultMaxIndex,
+		strictKeys: true,
+		want:       &indexedType{ItemPtr: &[]bracketItem{{SKU: 1}, {Qty: intp(2)}}},
+	}, {
+		name:       "indexed slice of map pointers",
+		data:       "metas.0.a=1&metas.1.b=2&m.c=3",
+		max:        defaultMaxIndex,
+		strictKeys: true,
+		want: &indexedType{
+			Metas:   []*map[string]string{{"a": "1"}, {"b": "2"}},
+			MetaPtr: &map[string]int{"c": 3},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data)).WithMaxIndex(tt.max)
+			if tt.strict {
+				d.DisallowSparseIndexes()
+			}
+			if tt.strictKeys {
+				d.DisallowUnknownFields()
+			}
+			dst := &indexedType{}
+			if err := d.Decode(dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("got %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		limits Limits
+		err    error
+	}{{
+		name:   "within limits",
+		data:   "a=1&a=2&bb=3",
+		limits: Limits{MaxBodySize: 12, MaxKeys: 2, MaxValues: 2, MaxKeyLength: 2},
+	}, {
+		name:   "body too large",
+		data:   "a=1&a=2&bb=3",
+		limits: Limits{MaxBodySize: 11},
+		err:    &LimitError{Limit: "MaxBodySize", Max: 11, Value: 12},
+	}, {
+		name:   "too many keys",
+		data:   "a=1&a=2&bb=3",
+		limits: Limits{MaxKeys: 1},
+		err:    &LimitError{Limit: "MaxKeys", Max: 1, Value: 2},
+	}, {
+		name:   "too many values",
+		data:   "a=1&a=2&bb=3",
+		limits: Limits{MaxValues: 1},
+		err:    &LimitError{Limit: "MaxValues", Max: 1, Value: 2, Key: "a"},
+	}, {
+		name:   "key too long",
+		data:   "a=1&a=2&bb=3",
+		limits: Limits{MaxKeyLength: 1},
+		err:    &LimitError{Limit: "MaxKeyLength", Max: 1, Value: 2, Key: "bb"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := map[string][]string{}
+			d := NewDecoder(strings.NewReader(tt.data)).WithLimits(tt.limits)
+			if err := d.Decode(&dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("Decode got error %v, want %v", err, tt.err)
+			}
+
+			defer func(limits Limits) { DefaultLimits = limits }(DefaultLimits)
+			DefaultLimits = tt.limits
+
+			dst = map[string][]string{}
+			if err := Unmarshal([]byte(tt.data), &dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("Unmarshal got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		style PathStyle
+		dst   interface{}
+		err   error
+	}{{
+		name: "all keys known",
+		data: nestedValString,
+		dst:  &nestedType{},
+	}, {
+		name: "unknown keys",
+		data: "name=foo&nmae=bar&address.city=x&address.town=y&zip=1",
+		dst:  &nestedType{},
+		err:  &UnknownKeysError{Keys: []string{"address.town", "nmae", "zip"}},
+	}, {
+		name: "empty values of known keys",
+		data: "name=&address.city=",
+		dst:  &nestedType{},
+	}, {
+		name:  "bracket multi-valued keys",
+		data:  "user[emails][]=a&user[emails][]=b&user[nick]=x&items[0][sku]=1&items[0][size]=2",
+		style: PathBracket,
+		dst:   &bracketType{},
+		err:   &UnknownKeysError{Keys: []string{"items[0][size]", "user[nick]"}},
+	}, {
+		name: "indexed slices and maps",
+		data: "items.0.sku=1&items.x.sku=2&tags.0=a",
+		dst:  &indexedType{},
+		err:  &UnknownKeysError{Keys: []string{"items.x.sku"}},
+	}, {
+		name: "map fields",
+		data: mapValString + "&mta.color=red",
+		dst:  &mapType{},
+		err:  &UnknownKeysError{Keys: []string{"mta.color"}},
+	}, {
+		name: "skipped fields",
+		data: "Bool=true&-=1",
+		dst: &struct {
+			Bool bool
+			Skip int `form:"-"`
+		}{},
+		err: &UnknownKeysError{Keys: []string{"-"}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data)).WithPathStyle(tt.style).DisallowUnknownFields()
+			if err := d.Decode(tt.dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+var errBadText = errors.New("bad text")
+
+type failingText struct{}
+
+func (*failingText) UnmarshalText(text []byte) error {
+	return errBadText
+}
+
+type collectType struct {
+	Int    int            `form:"int"`
+	Bool   bool           `form:"bool"`
+	Floats []float64      `form:"floats"`
+	Text   failingText    `form:"text"`
+	Counts map[string]int `form:"counts"`
+	Geo    nestedGeo      `form:"geo"`
+	Name   string         `form:"name"`
+}
+
+func TestDecoderCollectErrors(t *testing.T) {
+	data := "int=a&bool=b&floats=1.5&floats=c&floats=2.5&text=x&counts.a=1&counts.b=d&geo.lat=e&geo.lng=3&name=foo"
+	want := DecodeErrors{
+		&ValueError{Key: "int", Value: "a", Field: "Int", Type: reflect.TypeOf(0), Err: numError("ParseInt", "a", strconv.ErrSyntax)},
+		&ValueError{Key: "bool", Value: "b", Field: "Bool", Type: reflect.TypeOf(false), Err: numError("ParseBool", "b", strconv.ErrSyntax)},
+		&ValueError{Key: "floats", Value: "c", Index: 1, Field: "Floats[1]", Type: reflect.TypeOf(0.0), Err: numError("ParseFloat", "c", strconv.ErrSyntax)},
+		&ValueError{Key: "text", Value: "x", Field: "Text", Type: reflect.TypeOf(failingText{}), Err: errBadText},
+		&ValueError{Key: "counts.b", Value: "d", Field: `Counts["b"]`, Type: reflect.TypeOf(0), Err: numError("ParseInt", "d", strconv.ErrSyntax)},
+		&ValueError{Key: "geo.lat", Value: "e", Field: "Geo.Lat", Type: reflect.TypeOf(0.0), Err: numError("ParseFloat", "e", strconv.ErrSyntax)},
+	}
+
+	dst := new(collectType)
+	err := NewDecoder(strings.NewReader(data)).CollectErrors().Decode(dst)
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	if dst.Name != "foo" || dst.Geo.Lng != 3 || !reflect.DeepEqual(dst.Floats, []float64{1.5, 0, 2.5}) || dst.Counts["a"] != 1 {
+		t.Errorf("got %+v", dst)
+	}
+
+	// unknown keys are reported along with the value errors
+	data = "int=a&nmae=foo"
+	want = DecodeErrors{
+		&ValueError{Key: "int", Value: "a", Field: "Int", Type: reflect.TypeOf(0), Err: numError("ParseInt", "a", strconv.ErrSyntax)},
+		&UnknownKeysError{Keys: []string{"nmae"}},
+	}
+	err = NewDecoder(strings.NewReader(data)).CollectErrors().DisallowUnknownFields().Decode(new(collectType))
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+
+	// without the option only the first error is returned
+	data = "int=a&bool=b"
+	wantErr := &ValueError{Key: "int", Value: "a", Field: "Int", Type: reflect.TypeOf(0), Err: numError("ParseInt", "a", strconv.ErrSyntax)}
+	if err := NewDecoder(strings.NewReader(data)).Decode(new(collectType)); !reflect.DeepEqual(err, wantErr) {
+		t.Errorf("got error %v, want %v", err, wantErr)
+	}
+}
+
+func TestValueError(t *testing.T) {
+	err := Unmarshal([]byte("Int8=128"), &intType{})
+	if !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("errors.Is(%v, strconv.ErrRange) got false, want true", err)
+	}
+	want := `form: "Int8" value "128" could not be parsed into int8: value out of range`
+	if got := err.Error(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	err = Unmarshal([]byte("text=x"), &collectType{})
+	if !errors.Is(err, errBadText) {
+		t.Errorf("errors.Is(%v, errBadText) got false, want true", err)
+	}
+}
+
+type defaultType struct {
+	Name   string        `form:"name,default=anonymous"`
+	Age    *int          `form:"age,default=18"`
+	Tags   []string      `form:"tags,default=a|b|c"`
+	Ints   []int         `form:"ints,default=1|2"`
+	Text   *marshalSlice `form:"text,default=x"`
+	Geo    nestedGeo     `form:"geo"`
+	NoDef  string        `form:"nodef"`
+	BadDef int           `form:"baddef,default=abc"`
+}
+
+type pagingDefaults struct {
+	Page    int `form:"page,default=1"`
+	PerPage int `form:"per_page,default=20"`
+}
+
+type nestedDefaultType struct {
+	Name   string          `form:"name"`
+	Paging pagingDefaults  `form:"paging"`
+	Opts   *pagingDefaults `form:"opts"`
+	Plain  *struct {
+		Sort string `form:"sort"`
+	} `form:"plain"`
+	Next *nestedDefaultType `form:"next"`
+}
+
+func TestDecoderNestedDefaults(t *testing.T) {
+	tests := []struct {
+		data string
+		want *nestedDefaultType
+	}{{
+		data: "name=x",
+		want: &nestedDefaultType{Name: "x", Paging: pagingDefaults{1, 20}, Opts: &pagingDefaults{1, 20}},
+	}, {
+		data: "paging.page=3&opts.per_page=5",
+		want: &nestedDefaultType{Paging: pagingDefaults{3, 20}, Opts: &pagingDefaults{1, 5}},
+	}, {
+		data: "next.name=y",
+		want: &nestedDefaultType{Paging: pagingDefaults{1, 20}, Opts: &pagingDefaults{1, 20},
+			Next: &nestedDefaultType{Name: "y", Paging: pagingDefaults{1, 20}, Opts: &pagingDefaults{1, 20}}},
+	}}
+
+	for i, tt := range tests {
+		dst := new(nestedDefaultType)
+		if err := Unmarshal([]byte(tt.data), dst); err != nil {
+			t.Errorf("#%d: got error %v", i, err)
+		} else if !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("#%d: got %+v, want %+v", i, dst, tt.want)
+		}
+	}
+}
+
+func TestDecoderDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *defaultType
+		err  error
+	}{{
+		name: "absent keys",
+		data: "baddef=1",
+		want: &defaultType{Name: "anonymous", Age: intp(18), Tags: []string{"a", "b", "c"},
+			Ints: []int{1, 2}, Text: &marshalSlice{"x"}, BadDef: 1},
+	}, {
+		name: "empty values",
+		data: "name=&age=&tags=&baddef=1",
+		want: &defaultType{Name: "anonymous", Age: intp(18), Tags: []string{"a", "b", "c"},
+			Ints: []int{1, 2}, Text: &marshalSlice{"x"}, BadDef: 1},
+	}, {
+		name: "present values",
+		data: "name=joe&age=30&tags=x&ints=3&text=y&nodef=z&baddef=2",
+		want: &defaultType{Name: "joe", Age: intp(30), Tags: []string{"x"},
+			Ints: []int{3}, Text: &marshalSlice{"y"}, NoDef: "z", BadDef: 2},
+	}, {
+		name: "invalid default",
+		data: "",
+		err: &ValueError{Key: "baddef", Value: "abc", Field: "BadDef", Type: reflect.TypeOf(0),
+			Err: numError("ParseInt", "abc", strconv.ErrSyntax)},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := new(defaultType)
+			if err := Unmarshal([]byte(tt.data), dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("got %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+
+	// the keys decoded from their defaults are not unknown
+	data := "name=&baddef=1"
+	if err := NewDecoder(strings.NewReader(data)).DisallowUnknownFields().Decode(new(defaultType)); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+// searchQuery is a typical query string, as received by a search endpoint.
+type searchQuery struct {
+	Query    string   `form:"q"`
+	Page     int      `form:"page,default=1"`
+	PerPage  int      `form:"per_page,omitempty"`
+	Sort     string   `form:"sort"`
+	Desc     bool     `form:"desc"`
+	Tags     []string `form:"tags"`
+	MinPrice float64  `form:"min_price"`
+	MaxPrice float64  `form:"max_price"`
+	Lang     *string  `form:"lang"`
+	Filter   struct {
+		Brand  string `form:"brand"`
+		Rating uint8  `form:"rating"`
+	} `form:"filter"`
+}
+
+const searchQueryData = "q=running+shoes&page=3&per_page=50&sort=price&desc=true&tags=sale&tags=new" +
+	"&min_price=10.5&max_price=99.99&lang=en&filter.brand=acme&filter.rating=4"
+
+func BenchmarkDecode(b *testing.B) {
+	data := []byte(searchQueryData)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var q searchQuery
+		if err := Unmarshal(data, &q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeParallel(b *testing.B) {
+	data := []byte(searchQueryData)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var q searchQuery
+			if err := Unmarshal(data, &q); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// manyKeysData returns the data of an indexed slice with n elements
+// followed by junk keys, none of which match the destination's fields.
+func manyKeysData(n, junk int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		b.WriteString("items." + strconv.Itoa(i) + ".A=x&")
+	}
+	for i := 0; i < junk; i++ {
+		b.WriteString("j" + strconv.Itoa(i) + "=&")
+	}
+	return b.Bytes()
+}
+
+func BenchmarkDecodeManyKeys(b *testing.B) {
+	data := manyKeysData(1000, 50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v struct {
+			Items []struct{ A, B, C string } `form:"items"`
+		}
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	var q searchQuery
+	if err := Unmarshal([]byte(searchQueryData), &q); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(&q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoderReuse(b *testing.B) {
+	var q searchQuery
+	if err := Unmarshal([]byte(searchQueryData), &q); err != nil {
+		b.Fatal(err)
+	}
+	e := NewEncoder(ioutil.Discard)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(&q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncoderReuse(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEncoder(&b)
+	for i, tt := range []struct{ name, want string }{
+		{"a", "Name=a"},
+		{"b+c", "Name=b%2Bc"},
+	} {
+		b.Reset()
+		if err := e.Encode(&struct{ Name string }{tt.name}); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("#%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+
+	// nothing is written if encoding fails
+	b.Reset()
+	e.RegisterConverter(reflect.TypeOf(0), func(reflect.Value) (string, error) {
+		return "", errors.New("fail")
+	})
+	if err := e.Encode(&struct {
+		A string
+		B int
+	}{"a", 1}); err == nil || b.Len() != 0 {
+		t.Errorf("got error %v and output %q, want an error and no output", err, b.String())
+	}
+}
+
+func TestWriteQueryEscaped(t *testing.T) {
+	var s strings.Builder
+	for c := 0; c < 256; c++ {
+		s.WriteByte(byte(c))
+	}
+	s.WriteString(" ünïcödé &=+%")
+	var b bytes.Buffer
+	writeQueryEscaped(&b, s.String(), false)
+	if got, want := b.String(), url.QueryEscape(s.String()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
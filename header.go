@@ -0,0 +1,93 @@
+package form
+
+import (
+	"net/http"
+	"reflect"
+)
+
+const (
+	// HeaderTagKey is the key of the struct tags that bind fields to
+	// request headers, e.g. `header:"X-Request-ID"`.
+	HeaderTagKey = "header"
+	// CookieTagKey is the key of the struct tags that bind fields to
+	// request cookies, e.g. `cookie:"session"`.
+	CookieTagKey = "cookie"
+)
+
+// readHeader stores the values of the headers and the
+// cookies of the request req in the Decoder.
+func (d *Decoder) readHeader(req *http.Request) {
+	d.header = make(map[string][]string, len(req.Header))
+	for k, v := range req.Header {
+		d.header[k] = v
+	}
+	d.cookie = make(map[string][]string)
+	for _, c := range req.Cookies() {
+		d.cookie[c.Name] = append(d.cookie[c.Name], c.Value)
+	}
+}
+
+// decodeTagged decodes the values of src into the fields of the struct
+// dst, and of its nested structs, that have a tag with the given key,
+// e.g. `header:"Accept-Language"`. The names in the tags are converted
+// by canon, if it's not nil, into the keys of src. The fields are decoded
+// as form fields are, the tag options included, and a field decoded from
+// a header or a cookie overrides any value decoded from the form.
+func (d *Decoder) decodeTagged(dst reflect.Value, tagKey string, src map[string][]string, canon func(string) string, path string) error {
+	// Decode the fields as if src was the Decoder's
+	// only input, but keep the form's state intact.
+	style, form, keys, files, done := d.style, d.src, d.keys, d.files, d.done
+	d.style, d.src, d.keys, d.files, d.done = PathDot, src, nil, nil, make(map[string]bool)
+	defer func() { d.style, d.src, d.keys, d.files, d.done = style, form, keys, files, done }()
+
+	fields := cachedFields(dst.Type(), tagKey)
+	for i := range fields {
+		f := &fields[i]
+		fv := dst.Field(f.index)
+		fpath := joinPath(path, f.name)
+		if !f.tagged {
+			// Look for tagged fields in the nested structs and in the
+			// embedded structs. A nil pointer is set to a newly allocated
+			// struct only if any of the struct's fields is present in src,
+			// and only if the struct's type is not recursive.
+			if f.anonymous {
+				fpath = path
+			}
+			if fv.Kind() == reflect.Ptr && fv.IsNil() && fv.CanSet() && d.isNestedStruct(fv.Type()) {
+				if d.absentType(fv.Type()).recursive {
+					continue
+				}
+				nv := reflect.New(fv.Type().Elem())
+				n := len(d.present)
+				if err := d.decodeTagged(nv.Elem(), tagKey, src, canon, fpath); err != nil {
+					return err
+				}
+				if len(d.present) > n {
+					fv.Set(nv)
+				}
+				continue
+			}
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && d.isNestedStruct(fv.Type()) {
+				if err := d.decodeTagged(fv, tagKey, src, canon, fpath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.ignored {
+			continue
+		}
+
+		key := f.key
+		if canon != nil {
+			key = canon(key)
+		}
+		if err := d.decodeField(fv, f, key, fpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
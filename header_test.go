@@ -0,0 +1,110 @@
+package form
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type headerMeta struct {
+	Trace string `header:"x-trace-id"`
+	Lang  string `header:"Accept-Language,default=en"`
+}
+
+type headerType struct {
+	Name      string    `form:"name"`
+	RequestID string    `header:"X-Request-ID"`
+	Retries   *int      `header:"X-Retries"`
+	Forwarded []string  `header:"X-Forwarded-For"`
+	Since     time.Time `header:"X-Since"`
+	Session   string    `cookie:"session"`
+	Theme     string    `form:"theme" cookie:"theme"`
+	headerMeta
+	Meta *headerMeta
+}
+
+func TestDecodeRequestHeader(t *testing.T) {
+	r := httptest.NewRequest("POST", "/?RequestID=spoof&Session=spoof&theme=light",
+		strings.NewReader("name=joe&X-Request-ID=spoof"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Request-Id", "abc-123")
+	r.Header.Set("X-Retries", "3")
+	r.Header.Add("X-Forwarded-For", "10.0.0.1")
+	r.Header.Add("X-Forwarded-For", "10.0.0.2")
+	r.Header.Set("X-Since", "2019-03-04T05:06:07Z")
+	r.Header.Set("X-Trace-Id", "t1")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+	dst := new(headerType)
+	if err := DecodeRequest(r, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	retries := 3
+	want := &headerType{
+		Name:       "joe",
+		RequestID:  "abc-123",
+		Retries:    &retries,
+		Forwarded:  []string{"10.0.0.1", "10.0.0.2"},
+		Since:      time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC),
+		Session:    "s3cr3t",
+		Theme:      "dark",
+		headerMeta: headerMeta{Trace: "t1", Lang: "en"},
+		Meta:       &headerMeta{Trace: "t1", Lang: "en"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+
+	// A nil pointer to a struct none of whose headers is present stays nil.
+	r = httptest.NewRequest("GET", "/?name=ann", nil)
+	dst = new(headerType)
+	if err := DecodeRequest(r, dst); err != nil {
+		t.Fatal(err)
+	}
+	want = &headerType{Name: "ann", headerMeta: headerMeta{Lang: "en"}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+}
+
+func TestDecodeRequestHeaderErrors(t *testing.T) {
+	var dst struct {
+		Retries int    `header:"X-Retries"`
+		ID      string `header:"X-Request-ID,required"`
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Retries", "many")
+
+	err := NewRequestDecoder(r).EnableValidation().CollectErrors().Decode(&dst)
+	errs, ok := err.(DecodeErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("got error %v, want 2 DecodeErrors", err)
+	}
+	if verr, ok := errs[0].(*ValueError); !ok || verr.Key != "X-Retries" || verr.Field != "Retries" {
+		t.Errorf("got %v, want a *ValueError for X-Retries", errs[0])
+	}
+	if verr, ok := errs[1].(*ValidationError); !ok || verr.Key != "X-Request-Id" || verr.Rule != "required" {
+		t.Errorf("got %v, want a required *ValidationError for X-Request-Id", errs[1])
+	}
+}
+
+func TestEncoderSkipsHeaderFields(t *testing.T) {
+	src := &headerType{Name: "joe", RequestID: "abc", Session: "s", Theme: "dark"}
+	vals, err := EncodeValues(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"RequestID", "Retries", "Forwarded", "Since", "Session", "Trace", "Lang", "Meta"} {
+		if _, ok := vals[k]; ok {
+			t.Errorf("got encoded header field %q", k)
+		}
+	}
+	if vals.Get("name") != "joe" || vals.Get("theme") != "dark" {
+		t.Errorf("got %v, want name and theme", vals)
+	}
+}
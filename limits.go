@@ -0,0 +1,82 @@
+package form
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits specifies the limits a Decoder imposes on its input. A zero field
+// means that the corresponding limit is not enforced, unless documented
+// otherwise.
+type Limits struct {
+	// The maximum number of bytes of a multipart body's files that are
+	// kept in memory, the rest is stored on disk in temporary files.
+	// If zero, 32 MB is used.
+	MaxMemory int64
+	// The maximum size, in bytes, of a single uploaded file.
+	MaxFileSize int64
+	// The maximum size, in bytes, of the body read by the Decoder.
+	MaxBodySize int64
+	// The maximum number of parts in a multipart body.
+	MaxParts int
+	// The maximum number of distinct keys in a URL-encoded body.
+	MaxKeys int
+	// The maximum number of values per key in a URL-encoded body.
+	MaxValues int
+	// The maximum length, in bytes, of a key in a URL-encoded body.
+	MaxKeyLength int
+}
+
+// DefaultLimits are the limits imposed by Unmarshal and by the Decoders
+// whose limits were not set with WithLimits. By default no limits are
+// enforced, programs that decode untrusted input should set the limits
+// appropriate for their use, before any decoding takes place.
+var DefaultLimits Limits
+
+// A LimitError is returned by the Decoder, or Unmarshal,
+// if the input exceeds one of the limits imposed on it.
+type LimitError struct {
+	// The name of the exceeded limit, i.e. the name of
+	// the corresponding field of the Limits type.
+	Limit string
+	// The value of the exceeded limit.
+	Max int64
+	// The observed value that exceeded the limit.
+	Value int64
+	// The key of the offending value, if any.
+	Key string
+}
+
+func (err *LimitError) Error() string {
+	if err.Key != "" {
+		return fmt.Sprintf("form: %q value exceeds the %s limit of %d (got %d)", err.Key, err.Limit, err.Max, err.Value)
+	}
+	return fmt.Sprintf("form: input exceeds the %s limit of %d (got %d)", err.Limit, err.Max, err.Value)
+}
+
+// limitReader reads from r and fails with a LimitError
+// once more than max bytes have been read.
+type limitReader struct {
+	r     io.Reader
+	n     int64 // number of bytes read so far
+	max   int64
+	limit string
+	err   *LimitError
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	if lr.err != nil {
+		return 0, lr.err
+	}
+	// Allow reading one byte past the limit to be
+	// able to tell whether the limit was exceeded.
+	if rem := lr.max - lr.n + 1; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := lr.r.Read(p)
+	if lr.n += int64(n); lr.n > lr.max {
+		lr.err = &LimitError{Limit: lr.limit, Max: lr.max, Value: lr.n}
+		return n, lr.err
+	}
+	return n, err
+}
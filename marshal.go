@@ -0,0 +1,187 @@
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+)
+
+// FormUnmarshaler is the interface implemented by types that can decode
+// all of the values of their key at once, e.g. a set of flags. The Decoder
+// calls UnmarshalForm, instead of decoding the values one by one, with the
+// values of the key in the order they appear in the input.
+type FormUnmarshaler interface {
+	UnmarshalForm(values []string) error
+}
+
+// FormValuesUnmarshaler is the interface implemented by types that decode
+// the values nested inside their key themselves, e.g. a money type with
+// amount and currency keys. The keys of the values passed to UnmarshalForm
+// are relative to the prefix, which is the type's own key or empty at the
+// top level, e.g. "amount" for "price.amount", and the values of the key
+// itself are passed under the empty key.
+type FormValuesUnmarshaler interface {
+	UnmarshalForm(values url.Values, prefix string) error
+}
+
+// FormMarshaler is the interface implemented by types that encode
+// themselves as the values of their key.
+type FormMarshaler interface {
+	MarshalForm() ([]string, error)
+}
+
+// FormValuesMarshaler is the interface implemented by types that encode
+// themselves as values nested inside their key. The keys of the returned
+// values are relative to the prefix, as in FormValuesUnmarshaler.
+type FormValuesMarshaler interface {
+	MarshalForm(prefix string) (url.Values, error)
+}
+
+var (
+	formUnmarshalerType       = reflect.TypeOf(new(FormUnmarshaler)).Elem()
+	formValuesUnmarshalerType = reflect.TypeOf(new(FormValuesUnmarshaler)).Elem()
+	formMarshalerType         = reflect.TypeOf(new(FormMarshaler)).Elem()
+	formValuesMarshalerType   = reflect.TypeOf(new(FormValuesMarshaler)).Elem()
+)
+
+// implements reports whether v, or a pointer to v, implements the
+// interface type it. The pointer is considered only if v is addressable.
+func implements(v reflect.Value, it reflect.Type) bool {
+	t := v.Type()
+	if !hasMethods(t) {
+		return false
+	}
+	return t.Implements(it) || (t.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(it))
+}
+
+// hasMethods reports whether the type t, or a pointer to t, may have
+// methods. Only the predeclared types and the unnamed types, other than
+// structs with embedded fields, pointers and interfaces, have none.
+func hasMethods(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Ptr, reflect.Interface:
+		return true
+	}
+	return t.PkgPath() != ""
+}
+
+// interfaceOf returns v, or a pointer to v, as the interface type it.
+// A nil pointer v is set to a newly allocated value before it's returned.
+func interfaceOf(v reflect.Value, it reflect.Type) interface{} {
+	if !v.Type().Implements(it) {
+		v = v.Addr()
+	} else if v.Kind() != reflect.Ptr {
+		return v.Interface()
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Interface()
+}
+
+// isValuesUnmarshaler reports whether the values nested inside
+// the key of dst should be decoded by dst's UnmarshalForm method.
+func (d *Decoder) isValuesUnmarshaler(dst reflect.Value) bool {
+	return !d.hasConverter(dst.Type()) && implements(dst, formValuesUnmarshalerType)
+}
+
+// isUnmarshaler reports whether the values of the key
+// of dst should be decoded by dst's UnmarshalForm method.
+func (d *Decoder) isUnmarshaler(dst reflect.Value) bool {
+	return !d.hasConverter(dst.Type()) && implements(dst, formUnmarshalerType)
+}
+
+// unmarshalFormValues passes the values of the given key, and the values
+// nested inside the key, to the UnmarshalForm method of dst, which must
+// implement FormValuesUnmarshaler.
+func (d *Decoder) unmarshalFormValues(dst reflect.Value, key, path string) error {
+	vals := url.Values{}
+	if own := d.values(key); len(own) > 0 {
+		vals[""] = own
+		d.markDone(key)
+	}
+	prefix := d.style.prefix(key)
+	if key == "" {
+		prefix = ""
+	}
+	for _, k := range d.keysWithPrefix(prefix) {
+		rel, ok := k, key == ""
+		if !ok {
+			rel, ok = d.style.relative(k, prefix)
+		}
+		if ok {
+			vals[rel] = d.src[k]
+			d.done[k] = true
+		}
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+	d.present[path] = true
+
+	u := interfaceOf(dst, formValuesUnmarshalerType).(FormValuesUnmarshaler)
+	if err := u.UnmarshalForm(vals, key); err != nil {
+		return d.valueError(key, "", 0, path, dst.Type(), err)
+	}
+	return nil
+}
+
+// formMarshalerOf returns rv, or a pointer to rv, as a FormMarshaler or a
+// FormValuesMarshaler, or nil if it implements neither or is a nil pointer.
+func formMarshalerOf(rv reflect.Value) interface{} {
+	if !rv.CanInterface() {
+		return nil
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		switch m := rv.Interface().(type) {
+		case FormMarshaler, FormValuesMarshaler:
+			return m
+		}
+		rv = rv.Elem()
+	}
+	if rv.CanAddr() {
+		rv = rv.Addr()
+	}
+	switch m := rv.Interface().(type) {
+	case FormMarshaler, FormValuesMarshaler:
+		return m
+	}
+	return nil
+}
+
+// marshalForm encodes the marshaler m as the values of the given key,
+// or as values nested inside the key.
+func (e *Encoder) marshalForm(key string, m interface{}) error {
+	switch m := m.(type) {
+	case FormMarshaler:
+		vals, err := m.MarshalForm()
+		if err != nil {
+			return err
+		}
+		if mkey := e.style.multi(key); mkey != "" && len(vals) > 1 {
+			key = mkey
+		}
+		for _, val := range vals {
+			e.add(key, val)
+		}
+	case FormValuesMarshaler:
+		vals, err := m.MarshalForm(key)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(vals))
+		for k := range vals {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, val := range vals[k] {
+				e.add(e.style.rejoin(key, k), val)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package form
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// money decodes itself from the "amount" and "currency" keys nested inside its key.
+type money struct {
+	Amount   int
+	Currency string
+}
+
+var errNoCurrency = errors.New("no currency")
+
+func (m *money) UnmarshalForm(vals url.Values, prefix string) error {
+	if m.Currency = vals.Get("currency"); m.Currency == "" {
+		return errNoCurrency
+	}
+	n, err := strconv.Atoi(vals.Get("amount"))
+	m.Amount = n
+	return err
+}
+
+func (m money) MarshalForm(prefix string) (url.Values, error) {
+	return url.Values{"amount": {strconv.Itoa(m.Amount)}, "currency": {m.Currency}}, nil
+}
+
+// flags decodes itself from all of the values of its key.
+type flags map[string]bool
+
+func (f *flags) UnmarshalForm(vals []string) error {
+	*f = flags{}
+	for _, v := range vals {
+		for _, s := range strings.Fields(v) {
+			(*f)[s] = true
+		}
+	}
+	return nil
+}
+
+func (f flags) MarshalForm() ([]string, error) {
+	vals := []string{}
+	for _, s := range []string{"a", "b", "c"} {
+		if f[s] {
+			vals = append(vals, s)
+		}
+	}
+	return vals, nil
+}
+
+type formMarshalType struct {
+	Name   string  `form:"name"`
+	Price  money   `form:"price"`
+	Total  *money  `form:"total"`
+	Prices []money `form:"prices"`
+	Flags  flags   `form:"flags"`
+}
+
+func TestDecoderFormUnmarshaler(t *testing.T) {
+	tests := []struct {
+		data  string
+		style PathStyle
+		want  *formMarshalType
+		err   error
+	}{{
+		data: "name=x&price.amount=10&price.currency=EUR&total.amount=20&total.currency=USD" +
+			"&prices.1.amount=2&prices.1.currency=EUR&prices.0.amount=1&prices.0.currency=EUR&flags=a+b&flags=c",
+		want: &formMarshalType{
+			Name:   "x",
+			Price:  money{10, "EUR"},
+			Total:  &money{20, "USD"},
+			Prices: []money{{1, "EUR"}, {2, "EUR"}},
+			Flags:  flags{"a": true, "b": true, "c": true},
+		},
+	}, {
+		data:  "price[amount]=10&price[currency]=EUR&flags[]=a",
+		style: PathBracket,
+		want:  &formMarshalType{Price: money{10, "EUR"}, Flags: flags{"a": true}},
+	}, {
+		data: "price.amount=10",
+		err: &ValueError{Key: "price", Field: "Price", Type: reflect.TypeOf(money{}),
+			Err: errNoCurrency},
+	}}
+
+	for i, tt := range tests {
+		dst := new(formMarshalType)
+		d := NewDecoder(strings.NewReader(tt.data)).WithPathStyle(tt.style).DisallowUnknownFields()
+		err := d.Decode(dst)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("#%d: got error %v, want %v", i, err, tt.err)
+		} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("#%d: got %+v, want %+v", i, dst, tt.want)
+		}
+	}
+}
+
+func TestDecoderFormValuesUnmarshalerTopLevel(t *testing.T) {
+	dst := new(money)
+	if err := NewDecoder(strings.NewReader("amount=5&currency=GBP")).Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := (money{5, "GBP"}); *dst != want {
+		t.Errorf("got %+v, want %+v", *dst, want)
+	}
+}
+
+func TestEncoderFormMarshaler(t *testing.T) {
+	src := &formMarshalType{
+		Name:  "x",
+		Price: money{10, "EUR"},
+		Flags: flags{"a": true, "c": true},
+	}
+
+	tests := []struct {
+		style PathStyle
+		want  string
+	}{{
+		style: PathDot,
+		want:  "name=x&price.amount=10&price.currency=EUR&flags=a&flags=c",
+	}, {
+		style: PathBracket,
+		want:  "name=x&price%5Bamount%5D=10&price%5Bcurrency%5D=EUR&flags%5B%5D=a&flags%5B%5D=c",
+	}}
+
+	for i, tt := range tests {
+		var b strings.Builder
+		if err := NewEncoder(&b).WithPathStyle(tt.style).Encode(src); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("#%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := NewEncoder(&b).Encode(&money{5, "GBP"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "amount=5&currency=GBP"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package form
+
+import (
+	"strings"
+)
+
+// PathStyle specifies the syntax of the keys that address nested values.
+type PathStyle uint8
+
+const (
+	// PathDot separates the segments of a key with a dot,
+	// e.g. "user.name", "user.emails", "items.0.sku".
+	PathDot PathStyle = iota
+	// PathBracket encloses the nested segments of a key in square
+	// brackets, e.g. "user[name]", "user[emails][]", "items[0][sku]".
+	// This is the syntax used by jQuery, Rails and PHP clients.
+	PathBracket
+)
+
+// join returns the key of a nested value by joining it to the key of
+// its parent. An empty prefix denotes a top-level value in which case
+// the key is returned as is.
+func (s PathStyle) join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if s == PathBracket {
+		return prefix + "[" + key + "]"
+	}
+	return prefix + "." + key
+}
+
+// prefix returns the string with which start all
+// the keys of the values nested inside the given key.
+func (s PathStyle) prefix(key string) string {
+	if s == PathBracket {
+		return key + "["
+	}
+	return key + "."
+}
+
+// multi returns the key that, in the given style, marks a value
+// as an element of a multi-valued key, e.g. "emails[]". In styles
+// that have no such marker multi returns an empty string.
+func (s PathStyle) multi(key string) string {
+	if s == PathBracket {
+		return key + "[]"
+	}
+	return ""
+}
+
+// hasSeparator reports whether the segment seg contains the
+// characters that separate the segments of the keys in the style.
+func (s PathStyle) hasSeparator(seg string) bool {
+	if s == PathBracket {
+		return strings.ContainsAny(seg, "[]")
+	}
+	return strings.Contains(seg, ".")
+}
+
+// head returns the first segment of key, e.g. "user" for "user.name".
+func (s PathStyle) head(key string) string {
+	sep := byte('.')
+	if s == PathBracket {
+		sep = '['
+	}
+	if i := strings.IndexByte(key, sep); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// segment returns the first segment of key that follows the given prefix,
+// where prefix is the result of a call to s.prefix. The ok return value
+// reports whether key has the prefix and a well-formed segment.
+func (s PathStyle) segment(key, prefix string) (seg string, ok bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	rest := key[len(prefix):]
+	if s == PathBracket {
+		i := strings.IndexByte(rest, ']')
+		if i < 0 {
+			return "", false
+		}
+		return rest[:i], true
+	}
+	if i := strings.IndexByte(rest, '.'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest, true
+}
+
+// relative returns the key of a value nested inside the key of the given
+// prefix, where prefix is the result of a call to s.prefix, relative to
+// that key, e.g. "amount" for "price.amount", or "tags[]" for
+// "post[tags][]". The ok return value reports whether key has the prefix
+// and a well-formed first segment.
+func (s PathStyle) relative(key, prefix string) (rel string, ok bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	rest := key[len(prefix):]
+	if s == PathBracket {
+		i := strings.IndexByte(rest, ']')
+		if i <= 0 {
+			return "", false
+		}
+		return rest[:i] + rest[i+1:], true
+	}
+	return rest, rest != ""
+}
+
+// rejoin returns the key of a value, relative to the given prefix key,
+// as a key nested inside the prefix. It is the inverse of relative.
+func (s PathStyle) rejoin(prefix, rel string) string {
+	if prefix == "" || rel == "" {
+		return prefix + rel
+	}
+	if s == PathBracket {
+		head, rest := rel, ""
+		if i := strings.IndexByte(rel, '['); i >= 0 {
+			head, rest = rel[:i], rel[i:]
+		}
+		return prefix + "[" + head + "]" + rest
+	}
+	return prefix + "." + rel
+}
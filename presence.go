@@ -0,0 +1,41 @@
+package form
+
+import (
+	"sort"
+)
+
+// Present reports whether the input of the last call to Decode contained
+// the value of the struct field with the given Go path, e.g. "Name" or
+// "Address.City". A field counts as present even if its value was empty,
+// which allows telling apart an empty value from an absent one. A struct,
+// slice or map field counts as present if any of its nested values does.
+// A field that was decoded from its default value is not present.
+func (d *Decoder) Present(path string) bool {
+	return d.present[path]
+}
+
+// PresentFields returns the sorted Go paths of the struct fields
+// that were present in the input of the last call to Decode.
+func (d *Decoder) PresentFields() []string {
+	paths := make([]string, 0, len(d.present))
+	for p := range d.present {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// PresentKeys returns the sorted keys from the input of the last call to
+// Decode that were decoded into the fields of the destination value.
+func (d *Decoder) PresentKeys() []string {
+	keys := []string{}
+	for k := range d.done {
+		if _, ok := d.src[k]; ok {
+			keys = append(keys, k)
+		} else if _, ok := d.files[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
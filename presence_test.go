@@ -0,0 +1,82 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderPresence(t *testing.T) {
+	data := "name=&address.city=x&items.0.sku=1&meta.color=red&nodef=&tags=a"
+	dst := new(struct {
+		Name    string            `form:"name"`
+		Age     int               `form:"age,default=18"`
+		Address nestedAddress     `form:"address"`
+		Billing *nestedAddress    `form:"billing"`
+		Items   []bracketItem     `form:"items"`
+		Meta    map[string]string `form:"meta"`
+		Tags    []string          `form:"tags"`
+		Other   string            `form:"other"`
+	})
+
+	d := NewDecoder(strings.NewReader(data))
+	if err := d.Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	wantFields := []string{
+		"Address",
+		"Address.City",
+		"Items",
+		"Items[0].SKU",
+		"Meta",
+		`Meta["color"]`,
+		"Name",
+		"Tags",
+	}
+	if got := d.PresentFields(); !reflect.DeepEqual(got, wantFields) {
+		t.Errorf("PresentFields got %q, want %q", got, wantFields)
+	}
+
+	wantKeys := []string{"address.city", "items.0.sku", "meta.color", "name", "tags"}
+	if got := d.PresentKeys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("PresentKeys got %q, want %q", got, wantKeys)
+	}
+
+	for path, want := range map[string]bool{
+		"Name":         true,
+		"Age":          false,
+		"Address.City": true,
+		"Address.Geo":  false,
+		"Billing":      false,
+		"Other":        false,
+	} {
+		if got := d.Present(path); got != want {
+			t.Errorf("Present(%q) got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestDecoderPresenceReuse(t *testing.T) {
+	d := NewDecoder(strings.NewReader("name=joe&age=30"))
+	first := new(struct {
+		Name string `form:"name"`
+		Age  int    `form:"age"`
+	})
+	if err := d.Decode(first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := new(struct {
+		Name string `form:"name"`
+	})
+	if err := d.Decode(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.Name != "joe" {
+		t.Errorf("Name got %q, want %q", second.Name, "joe")
+	}
+	if got, want := d.PresentKeys(), []string{"name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PresentKeys got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package form
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// Precedence specifies how a request Decoder merges the values
+// of the request's URL query with the values of its body.
+type Precedence uint8
+
+const (
+	// PreferBody decodes the query values of a key only if the body
+	// has no values for the key. This is the default precedence.
+	PreferBody Precedence = iota
+	// PreferQuery decodes the body values of a key only if the
+	// query has no values for the key.
+	PreferQuery
+	// IgnoreQuery decodes only the values of the body.
+	IgnoreQuery
+)
+
+// DecodeRequest decodes the URL query and the form body of the request
+// r into the value pointed to by v, as does the Decoder returned by
+// NewRequestDecoder with its default settings.
+func DecodeRequest(r *http.Request, v interface{}) error {
+	return NewRequestDecoder(r).Decode(v)
+}
+
+// NewRequestDecoder returns a new decoder that reads the URL query and the
+// form body of the request r. The body is read only if the request's method
+// is POST, PUT or PATCH, as by http.Request.ParseForm, and it is decoded as
+// "multipart/form-data" or "application/x-www-form-urlencoded" data according
+// to the request's Content-Type, bodies of other types are ignored. The
+// limits set on the Decoder apply to the body and to the query separately.
+//
+// The "source" option of a struct field's tag restricts the field to the
+// values of the query or of the body, regardless of the precedence, e.g.
+// `form:"page,source=query"` or `form:"amount,source=body"`, the latter
+// ensures that the field cannot be set through the URL. The default
+// source is "any", i.e. the values merged according to the precedence.
+//
+// The fields whose tags have the "header" or "cookie" key are decoded
+// from the request's headers or cookies with the given names, e.g.
+// `header:"X-Request-ID"` or `cookie:"session"`, using the same options
+// as form fields. Such fields are not decoded from the form unless they
+// also have a form tag, in which case the header or cookie prevails.
+//
+// The multipart form read from the body is stored in the request's
+// MultipartForm field, if it's nil, so that the temporary files are
+// removed by the http.Server once the request has been handled.
+func NewRequestDecoder(r *http.Request) *Decoder {
+	var d *Decoder
+	ct := r.Header.Get("Content-Type")
+	mt, _, _ := mime.ParseMediaType(ct)
+	switch {
+	case !hasFormBody(r):
+		d = newDecoder(make(map[string][]string))
+	case mt == "multipart/form-data":
+		d = NewDecoderMultipart(r.Body, ct)
+	case mt == "application/x-www-form-urlencoded":
+		d = NewDecoder(r.Body)
+	default:
+		d = newDecoder(make(map[string][]string))
+	}
+	d.req = r
+	return d
+}
+
+// hasFormBody reports whether the body of
+// the request r should be read as a form.
+func hasFormBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	return r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+}
+
+// WithPrecedence sets how the Decoder merges the values of the request's
+// URL query with the values of its body. It is used only by the Decoders
+// returned by NewRequestDecoder.
+func (d *Decoder) WithPrecedence(p Precedence) *Decoder {
+	d.precedence = p
+	return d
+}
+
+// mergeQuery merges the values of the URL query of the
+// Decoder's request into its src, once the body's been read.
+func (d *Decoder) mergeQuery() error {
+	req := d.req
+	d.req = nil
+	if d.form != nil && req.MultipartForm == nil {
+		req.MultipartForm = d.form
+	}
+	d.readHeader(req)
+	if d.src == nil {
+		d.src = make(map[string][]string)
+	}
+	d.body = make(map[string][]string, len(d.src))
+	for k, v := range d.src {
+		d.body[k] = v
+	}
+	d.query = make(map[string][]string)
+	if req.URL == nil {
+		return nil
+	}
+
+	query, err := parseBytes([]byte(req.URL.RawQuery), d.limits)
+	if err != nil {
+		return err
+	}
+	d.query = query
+	if d.precedence == IgnoreQuery {
+		return nil
+	}
+	for k, v := range query {
+		if _, ok := d.src[k]; !ok || d.precedence == PreferQuery {
+			d.src[k] = v
+		}
+	}
+	return nil
+}
+
+// useSource sets the Decoder's values, and files, to those of the source
+// specified by the "source" option of the field f, whose Go path is the
+// given path, and returns a function that restores them. The source is
+// one of "query", "body" or "any", which stands for the merged values.
+// The option is honoured only by the Decoders returned by NewRequestDecoder.
+func (d *Decoder) useSource(f *field, path string) (restore func(), err error) {
+	switch f.source {
+	case "", "any":
+		return noRestore, nil
+	case "query", "body":
+	default:
+		return nil, fmt.Errorf("form: invalid source option %q on field %s", f.source, path)
+	}
+	if d.query == nil {
+		return noRestore, nil
+	}
+
+	src, keys, files := d.src, d.keys, d.files
+	if f.source == "query" {
+		d.src, d.files = d.query, nil
+	} else {
+		d.src = d.body
+	}
+	d.keys = nil
+	return func() { d.src, d.keys, d.files = src, keys, files }, nil
+}
+
+func noRestore() {}
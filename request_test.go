@@ -0,0 +1,217 @@
+package form
+
+import (
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type requestType struct {
+	Page  int      `form:"page"`
+	Name  string   `form:"name"`
+	Tags  []string `form:"tags"`
+	Token string   `form:"token"`
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		ctype      string
+		body       string
+		precedence Precedence
+		want       *requestType
+	}{{
+		name:   "query only",
+		method: "GET",
+		target: "/?page=2&tags=a&tags=b",
+		want:   &requestType{Page: 2, Tags: []string{"a", "b"}},
+	}, {
+		name:   "body is ignored for GET",
+		method: "GET",
+		target: "/?page=2",
+		ctype:  "application/x-www-form-urlencoded",
+		body:   "name=joe",
+		want:   &requestType{Page: 2},
+	}, {
+		name:   "body of unknown type is ignored",
+		method: "POST",
+		target: "/?page=2",
+		ctype:  "application/json",
+		body:   `{"name":"joe"}`,
+		want:   &requestType{Page: 2},
+	}, {
+		name:   "prefer body",
+		method: "POST",
+		target: "/?page=2&name=query&tags=q",
+		ctype:  "application/x-www-form-urlencoded; charset=utf-8",
+		body:   "name=body&tags=b1&tags=b2",
+		want:   &requestType{Page: 2, Name: "body", Tags: []string{"b1", "b2"}},
+	}, {
+		name:       "prefer query",
+		method:     "PUT",
+		target:     "/?page=2&name=query&tags=q",
+		ctype:      "application/x-www-form-urlencoded",
+		body:       "name=body&tags=b1&token=t",
+		precedence: PreferQuery,
+		want:       &requestType{Page: 2, Name: "query", Tags: []string{"q"}, Token: "t"},
+	}, {
+		name:       "ignore query",
+		method:     "PATCH",
+		target:     "/?page=2&name=query",
+		ctype:      "application/x-www-form-urlencoded",
+		body:       "name=body",
+		precedence: IgnoreQuery,
+		want:       &requestType{Name: "body"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.ctype != "" {
+				r.Header.Set("Content-Type", tt.ctype)
+			}
+			dst := new(requestType)
+			if err := NewRequestDecoder(r).WithPrecedence(tt.precedence).Decode(dst); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("got %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestMultipart(t *testing.T) {
+	body, ctype := multipartBody(t, [][2]string{{"name", "joe"}}, [][3]string{{"avatar", "a.png", "png"}})
+	r := httptest.NewRequest("POST", "/?page=3&name=query", body)
+	r.Header.Set("Content-Type", ctype)
+
+	var dst struct {
+		requestType
+		Avatar *multipart.FileHeader `form:"avatar"`
+	}
+	if err := DecodeRequest(r, &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Page != 3 || dst.Name != "joe" || dst.Avatar == nil || dst.Avatar.Filename != "a.png" {
+		t.Errorf("got %+v", dst)
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File["avatar"]) != 1 {
+		t.Errorf("got request MultipartForm %+v, want the decoded form", r.MultipartForm)
+	}
+}
+
+func TestDecodeRequestLimits(t *testing.T) {
+	limits := Limits{MaxBodySize: 8, MaxKeys: 2}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=joe&tags=a"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := NewRequestDecoder(r).WithLimits(limits).Decode(new(requestType))
+	if le, ok := err.(*LimitError); !ok || le.Limit != "MaxBodySize" {
+		t.Errorf("got error %v, want MaxBodySize *LimitError", err)
+	}
+
+	r = httptest.NewRequest("GET", "/?a=1&b=2&c=3", nil)
+	err = NewRequestDecoder(r).WithLimits(limits).Decode(new(requestType))
+	if le, ok := err.(*LimitError); !ok || le.Limit != "MaxKeys" {
+		t.Errorf("got error %v, want MaxKeys *LimitError", err)
+	}
+
+	// the body is not read by a GET decoder
+	r = httptest.NewRequest("GET", "/", strings.NewReader("name=joe&tags=a"))
+	if err := NewRequestDecoder(r).WithLimits(limits).Decode(new(requestType)); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != "name=joe&tags=a" {
+		t.Errorf("got unread body %q", b)
+	}
+}
+
+func TestEncodeValues(t *testing.T) {
+	got, err := EncodeValues(&nestedVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"name": {"foo"}, "address.street": {"Main St. 1"}, "address.city": {"Springfield"},
+		"address.geo.lat": {"44.05"}, "address.geo.lng": {"-123.09"}, "billing.street": {""},
+		"billing.city": {"Shelbyville"}, "billing.geo.lat": {"0"}, "billing.geo.lng": {"0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// EncodeValues is the inverse of Transform
+	dst := new(nestedType)
+	if err := Transform(got, dst); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(dst, &nestedVal) {
+		t.Errorf("got %+v, want %+v", dst, nestedVal)
+	}
+}
+
+func TestDecodeRequestSource(t *testing.T) {
+	type item struct {
+		SKU string `form:"sku"`
+	}
+	type source struct {
+		Page   int    `form:"page,source=query"`
+		Amount int    `form:"amount,source=body"`
+		Note   string `form:"note,source=any"`
+		Item   item   `form:"item,source=body"`
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		precedence Precedence
+		want       source
+	}{{
+		name:   "fields are read from their sources",
+		target: "/?page=2&amount=1000&item.sku=spoof&note=q",
+		body:   "page=9&amount=10&item.sku=abc&note=b",
+		want:   source{Page: 2, Amount: 10, Note: "b", Item: item{"abc"}},
+	}, {
+		name:       "sources ignore the precedence",
+		target:     "/?page=2&amount=1000&note=q",
+		body:       "page=9&note=b",
+		precedence: PreferQuery,
+		want:       source{Page: 2, Note: "q"},
+	}, {
+		name:       "query source with ignored query",
+		target:     "/?page=2&note=q",
+		precedence: IgnoreQuery,
+		want:       source{Page: 2},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			var dst source
+			if err := NewRequestDecoder(r).WithPrecedence(tt.precedence).Decode(&dst); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("got %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+
+	// the option is ignored by other decoders, unless it's invalid
+	var dst source
+	if err := Unmarshal([]byte("page=3&amount=4"), &dst); err != nil || dst.Page != 3 || dst.Amount != 4 {
+		t.Errorf("got %+v and error %v, want page 3 and amount 4", dst, err)
+	}
+	var bad struct {
+		Page int `form:"page,source=header"`
+	}
+	if err := Unmarshal([]byte("page=3"), &bad); err == nil {
+		t.Error("got nil error, want an invalid source option error")
+	}
+}
@@ -0,0 +1,178 @@
+package form
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"strings"
+)
+
+// A PartFunc receives the content of a file uploaded as part of a multipart
+// body that's being streamed by a Decoder. The filename and header are those
+// of the part and r reads the part's content. The r argument is valid only
+// until the PartFunc returns. An error returned by the PartFunc aborts the
+// decoding and is returned from Decode.
+type PartFunc func(filename string, header textproto.MIMEHeader, r io.Reader) error
+
+var (
+	partFuncType = reflect.TypeOf(PartFunc(nil))
+	readerType   = reflect.TypeOf(new(io.Reader)).Elem()
+)
+
+// StreamParts causes the Decoder to read its multipart body part by part,
+// without buffering the content of uploaded files. A file part is passed
+// to the struct field, associated with the part's name, of type PartFunc or
+// io.Reader. Since a part can be read only until the next part is read, the
+// Decoder stops reading the body at the first part it stores in an io.Reader
+// field, which should therefore be the last part of the body. Other parts
+// are decoded into the struct like they would be otherwise, except for files
+// without a matching field, which are discarded. Since the files are not
+// buffered, they cannot be decoded into fields of type File,
+// *multipart.FileHeader or []byte, a file whose field is of one of these
+// types results in an error.
+//
+// StreamParts has no effect on a Decoder that was not
+// returned by NewDecoderMultipart.
+func (d *Decoder) StreamParts() *Decoder {
+	d.stream = true
+	return d
+}
+
+// readStream reads the Decoder's multipart body and passes the file parts
+// to their corresponding fields in dst. The rest of the parts are stored
+// in the Decoder's src to be decoded once the body's been read.
+func (d *Decoder) readStream(dst reflect.Value) error {
+	r := d.r
+	d.r = nil
+	if max := d.limits.MaxBodySize; max > 0 {
+		r = &limitReader{r: r, max: max, limit: "MaxBodySize"}
+	}
+	maxMemory := d.limits.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	memory := int64(0) // memory used by the values
+
+	d.src = make(map[string][]string)
+	mr := multipart.NewReader(r, d.boundary)
+	for n := 1; ; n++ {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if lr, ok := r.(*limitReader); ok && lr.err != nil {
+				return lr.err
+			}
+			return err
+		}
+		if max := d.limits.MaxParts; max > 0 && n > max {
+			return &LimitError{Limit: "MaxParts", Max: int64(max), Value: int64(n)}
+		}
+
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+
+		var fv reflect.Value
+		if dst.Kind() == reflect.Struct {
+			fv = d.streamField(dst, "", name, isStreamType)
+		}
+		if !fv.IsValid() {
+			if p.FileName() != "" {
+				if dst.Kind() == reflect.Struct {
+					if fv := d.streamField(dst, "", name, isFileType); fv.IsValid() {
+						return fmt.Errorf("form: file %q cannot be streamed into a field of type %s", name, fv.Type())
+					}
+				}
+				d.discarded = append(d.discarded, name)
+				continue
+			}
+
+			// Read the value's content keeping track of the memory used.
+			b, err := ioutil.ReadAll(io.LimitReader(p, maxMemory-memory+1))
+			if err != nil {
+				return err
+			}
+			if memory += int64(len(b)); memory > maxMemory {
+				return &LimitError{Limit: "MaxMemory", Max: maxMemory, Value: memory, Key: name}
+			}
+			d.src[name] = append(d.src[name], string(b))
+			continue
+		}
+
+		var pr io.Reader = p
+		if max := d.limits.MaxFileSize; max > 0 {
+			pr = &limitReader{r: p, max: max, limit: "MaxFileSize"}
+		}
+		if fv.Type() == readerType {
+			fv.Set(reflect.ValueOf(pr))
+			return nil
+		}
+		if fn := fv.Interface().(PartFunc); fn != nil {
+			err := fn(p.FileName(), p.Header, pr)
+			if lr, ok := pr.(*limitReader); ok && lr.err != nil {
+				lr.err.Key = name
+				return lr.err
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isStreamType reports whether a streamed file can be passed
+// to a field of type t, i.e. t is PartFunc or io.Reader.
+func isStreamType(t reflect.Type) bool {
+	return t == partFuncType || t == readerType
+}
+
+// streamField returns the field of the struct value dst, or of one of
+// its nested structs, that is associated with the given key and whose
+// type is accepted by the given function. If there's no such field, the
+// returned value is invalid.
+func (d *Decoder) streamField(dst reflect.Value, prefix, key string, accept func(reflect.Type) bool) reflect.Value {
+	fields := cachedFields(dst.Type(), d.tagKey)
+	for i := range fields {
+		f := &fields[i]
+		if f.ignored {
+			continue
+		}
+
+		fv := dst.Field(f.index)
+		if f.anonymous && fv.Kind() == reflect.Struct {
+			if sf := d.streamField(fv, prefix, key, accept); sf.IsValid() {
+				return sf
+			}
+			continue
+		}
+
+		fkey := d.style.join(prefix, f.key)
+
+		if fkey == key && accept(fv.Type()) {
+			return fv
+		}
+		if d.isNestedStruct(fv.Type()) && strings.HasPrefix(key, d.style.prefix(fkey)) {
+			if fv.Kind() == reflect.Ptr && fv.IsNil() {
+				// Allocate the struct only if it has the field.
+				nv := reflect.New(fv.Type().Elem())
+				if sf := d.streamField(nv.Elem(), fkey, key, accept); sf.IsValid() {
+					fv.Set(nv)
+					return sf
+				}
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				fv = fv.Elem()
+			}
+			if sf := d.streamField(fv, fkey, key, accept); sf.IsValid() {
+				return sf
+			}
+		}
+	}
+	return reflect.Value{}
+}
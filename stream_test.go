@@ -0,0 +1,157 @@
+package form
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+type streamType struct {
+	Title  string   `form:"title"`
+	Tags   []string `form:"tags"`
+	Photos PartFunc `form:"photos"`
+	Meta   *struct {
+		Thumb PartFunc `form:"thumb"`
+	} `form:"meta"`
+	Other *struct {
+		Thumb PartFunc `form:"thumb"`
+	} `form:"other"`
+	Video io.Reader `form:"video"`
+}
+
+func TestDecoderStreamParts(t *testing.T) {
+	body, ctype := multipartBody(t, [][2]string{
+		{"title", "Hello"},
+		{"tags", "a"},
+		{"tags", "b"},
+	}, [][3]string{
+		{"photos", "a.jpg", "aaa"},
+		{"unknown", "u.txt", "discarded"},
+		{"photos", "b.jpg", "bbbb"},
+		{"meta.thumb", "t.png", "thumb"},
+		{"video", "v.mp4", "video-data"},
+		{"photos", "c.jpg", "not reached"},
+	})
+
+	var got []string
+	dst := new(streamType)
+	dst.Photos = func(filename string, header textproto.MIMEHeader, r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		got = append(got, filename+":"+string(b))
+		return err
+	}
+	dst.Meta = &struct {
+		Thumb PartFunc `form:"thumb"`
+	}{}
+	dst.Meta.Thumb = func(filename string, header textproto.MIMEHeader, r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		got = append(got, filename+":"+string(b))
+		return err
+	}
+
+	d := NewDecoderMultipart(body, ctype).StreamParts()
+	if err := d.Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a.jpg:aaa", "b.jpg:bbbb", "t.png:thumb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parts got %q, want %q", got, want)
+	}
+	if dst.Title != "Hello" || !reflect.DeepEqual(dst.Tags, []string{"a", "b"}) {
+		t.Errorf("values got %q %q", dst.Title, dst.Tags)
+	}
+	if dst.Other != nil {
+		t.Errorf("Other got %+v, want nil", dst.Other)
+	}
+	if dst.Video == nil {
+		t.Fatal("Video got nil")
+	}
+	if b, err := ioutil.ReadAll(dst.Video); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "video-data" {
+		t.Errorf("Video got %q, want %q", b, "video-data")
+	}
+}
+
+func TestDecoderStreamPartsErrors(t *testing.T) {
+	errPart := errors.New("part error")
+
+	tests := []struct {
+		name   string
+		limits Limits
+		fn     PartFunc
+		err    error
+	}{{
+		name: "PartFunc error",
+		fn: func(filename string, header textproto.MIMEHeader, r io.Reader) error {
+			return errPart
+		},
+		err: errPart,
+	}, {
+		name:   "file too large",
+		limits: Limits{MaxFileSize: 3},
+		fn: func(filename string, header textproto.MIMEHeader, r io.Reader) error {
+			_, err := ioutil.ReadAll(r)
+			return err
+		},
+		err: &LimitError{Limit: "MaxFileSize", Max: 3, Value: 4, Key: "photos"},
+	}, {
+		name:   "too many parts",
+		limits: Limits{MaxParts: 2},
+		err:    &LimitError{Limit: "MaxParts", Max: 2, Value: 3},
+	}, {
+		name:   "values too large",
+		limits: Limits{MaxMemory: 6},
+		err:    &LimitError{Limit: "MaxMemory", Max: 6, Value: 7, Key: "tags"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ctype := multipartBody(t, [][2]string{
+				{"title", "Hello"},
+				{"tags", "ab"},
+			}, [][3]string{
+				{"photos", "a.jpg", "aaaa"},
+			})
+
+			dst := &streamType{Photos: tt.fn}
+			d := NewDecoderMultipart(body, ctype).WithLimits(tt.limits).StreamParts()
+			if err := d.Decode(dst); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecoderStreamPartsUnknownFields(t *testing.T) {
+	body, ctype := multipartBody(t, [][2]string{
+		{"title", "Hello"},
+		{"subtitle", "World"},
+	}, [][3]string{
+		{"unknown", "u.txt", "discarded"},
+	})
+
+	d := NewDecoderMultipart(body, ctype).StreamParts().DisallowUnknownFields()
+	want := &UnknownKeysError{Keys: []string{"subtitle", "unknown"}}
+	if err := d.Decode(new(streamType)); !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
+
+func TestDecoderStreamPartsFileFields(t *testing.T) {
+	body, ctype := multipartBody(t, nil, [][3]string{
+		{"doc", "d.txt", "content"},
+	})
+
+	dst := new(struct {
+		Doc File `form:"doc"`
+	})
+	d := NewDecoderMultipart(body, ctype).StreamParts().DisallowUnknownFields()
+	want := `form: file "doc" cannot be streamed into a field of type form.File`
+	if err := d.Decode(dst); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
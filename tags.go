@@ -42,3 +42,35 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// Value returns the value of the first "name=value" option with the given
+// name. The ok return value reports whether the option was found. The
+// value of a "pattern" option extends to the end of the options, commas
+// included, therefore a pattern must be the last option in the tag.
+func (o tagOptions) Value(name string) (value string, ok bool) {
+	for _, opt := range o.list() {
+		if i := strings.IndexByte(opt, '='); i >= 0 && opt[:i] == name {
+			return opt[i+1:], true
+		}
+	}
+	return "", false
+}
+
+// list returns the options as a slice. The "pattern" option and
+// everything that follows it is returned as a single option.
+func (o tagOptions) list() []string {
+	var opts []string
+	s := string(o)
+	for s != "" {
+		if strings.HasPrefix(s, "pattern=") {
+			return append(opts, s)
+		}
+		var next string
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		opts = append(opts, s)
+		s = next
+	}
+	return opts
+}
@@ -22,3 +22,22 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagOptionsValue(t *testing.T) {
+	_, opts := parseTag("field,required,min=3,max=,pattern=^[a-z]{2,4}$")
+	for _, tt := range []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"min", "3", true},
+		{"max", "", true},
+		{"pattern", "^[a-z]{2,4}$", true},
+		{"required", "", false},
+		{"maxlen", "", false},
+	} {
+		if value, ok := opts.Value(tt.name); value != tt.value || ok != tt.ok {
+			t.Errorf("Value(%q) = %q, %v, want %q, %v", tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// timeLayouts maps the names of the layout presets to the layouts they
+// accept. The first layout of a preset is the one used for encoding. The
+// presets match the values of the HTML input elements of the same type.
+var timeLayouts = map[string][]string{
+	"date":           {"2006-01-02"},
+	"datetime-local": {"2006-01-02T15:04", "2006-01-02T15:04:05"},
+	"month":          {"2006-01"},
+	"time":           {"15:04", "15:04:05"},
+}
+
+// The name of the preset for ISO 8601 weeks, e.g. "2006-W01", which
+// cannot be expressed as a layout and is parsed and formatted manually.
+const weekLayout = "week"
+
+var errInvalidWeek = errors.New("invalid week")
+
+// WithLocation sets the location in which the Decoder interprets
+// times that carry no time zone information, e.g. the values of
+// the "date" and "datetime-local" layouts. The default is UTC.
+func (d *Decoder) WithLocation(loc *time.Location) *Decoder {
+	d.loc = loc
+	return d
+}
+
+// isTimeType reports whether the type t, or the type that
+// t points to, is decoded and encoded as a time value.
+func isTimeType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType || t == durationType
+}
+
+// decodeTime decodes the string src into dst if dst is a time value,
+// or a pointer to one, the ok return value reports whether it is.
+func (d *Decoder) decodeTime(dst reflect.Value, src, layout string) (ok bool, err error) {
+	if !isTimeType(dst.Type()) {
+		return false, nil
+	}
+
+	var v interface{}
+	if t := dst.Type(); t == timeType || (t.Kind() == reflect.Ptr && t.Elem() == timeType) {
+		v, err = d.parseTime(src, layout)
+	} else {
+		v, err = parseDuration(src)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	dst.Set(reflect.ValueOf(v))
+	return true, nil
+}
+
+// parseTime parses the string s using the given layout, or preset.
+func (d *Decoder) parseTime(s, layout string) (time.Time, error) {
+	loc := d.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	if layout == weekLayout {
+		return parseWeek(s, loc)
+	}
+
+	layouts, ok := timeLayouts[layout]
+	if !ok {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		layouts = []string{layout}
+	}
+	var err error
+	for _, l := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseWeek parses the ISO 8601 week s, e.g. "2006-W01", and returns
+// the time at the start of the week's Monday in the given location.
+func parseWeek(s string, loc *time.Location) (time.Time, error) {
+	if len(s) != 8 || s[4:6] != "-W" {
+		return time.Time{}, errInvalidWeek
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return time.Time{}, errInvalidWeek
+	}
+	week, err := strconv.Atoi(s[6:])
+	if err != nil || week < 1 || week > 53 {
+		return time.Time{}, errInvalidWeek
+	}
+
+	// The first week of a year is the one that contains January 4th.
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, loc)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	t := jan4.AddDate(0, 0, (week-1)*7-offset)
+	if y, w := t.ISOWeek(); y != year || w != week {
+		return time.Time{}, errInvalidWeek
+	}
+	return t, nil
+}
+
+// parseDuration parses the string s as a duration, e.g. "1h30m",
+// or as an integer number of nanoseconds.
+func parseDuration(s string) (time.Duration, error) {
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		n, nerr := strconv.ParseInt(s, 10, 64)
+		if nerr != nil {
+			return 0, err
+		}
+		dur = time.Duration(n)
+	}
+	return dur, nil
+}
+
+// encodeTime returns the string representation of rv, formatted using
+// the given layout, or preset, if rv is a time value or a non-nil pointer
+// to one, the ok return value reports whether it is. Durations are
+// formatted by their String method, times without a layout as RFC 3339.
+func encodeTime(rv reflect.Value, layout string) (s string, ok bool) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Type() {
+	case durationType:
+		return time.Duration(rv.Int()).String(), true
+	case timeType:
+		t := rv.Interface().(time.Time)
+		if layout == weekLayout {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week), true
+		}
+		if layouts, ok := timeLayouts[layout]; ok {
+			layout = layouts[0]
+		} else if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return t.Format(layout), true
+	}
+	return "", false
+}
@@ -0,0 +1,161 @@
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timeFields struct {
+	At       time.Time       `form:"at"`
+	Date     time.Time       `form:"date,layout=date"`
+	Local    *time.Time      `form:"local,layout=datetime-local"`
+	Month    time.Time       `form:"month,layout=month"`
+	Week     time.Time       `form:"week,layout=week"`
+	Clock    time.Time       `form:"clock,layout=time"`
+	Custom   time.Time       `form:"custom,layout=02/01/2006"`
+	Days     []time.Time     `form:"days,layout=date"`
+	Timeout  time.Duration   `form:"timeout"`
+	Delays   []time.Duration `form:"delays"`
+	Interval *time.Duration  `form:"interval"`
+}
+
+func date(y int, m time.Month, d, h, min, s int) time.Time {
+	return time.Date(y, m, d, h, min, s, 0, time.UTC)
+}
+
+func TestDecoderTime(t *testing.T) {
+	tests := []struct {
+		data string
+		want *timeFields
+		err  error
+	}{{
+		data: "at=2019-03-04T05:06:07Z&date=2019-03-04&local=2019-03-04T05:06&month=2019-03" +
+			"&week=2019-W10&clock=05:06:07&custom=04/03/2019&days=2019-03-04&days=2019-03-05",
+		want: &timeFields{
+			At:     date(2019, 3, 4, 5, 6, 7),
+			Date:   date(2019, 3, 4, 0, 0, 0),
+			Local:  timep(date(2019, 3, 4, 5, 6, 0)),
+			Month:  date(2019, 3, 1, 0, 0, 0),
+			Week:   date(2019, 3, 4, 0, 0, 0),
+			Clock:  date(0, 1, 1, 5, 6, 7),
+			Custom: date(2019, 3, 4, 0, 0, 0),
+			Days:   []time.Time{date(2019, 3, 4, 0, 0, 0), date(2019, 3, 5, 0, 0, 0)},
+		},
+	}, {
+		data: "local=2019-03-04T05:06:07&week=2020-W53&date=",
+		want: &timeFields{
+			Local: timep(date(2019, 3, 4, 5, 6, 7)),
+			Week:  date(2020, 12, 28, 0, 0, 0),
+		},
+	}, {
+		data: "timeout=1h30m&delays=1s&delays=250ms&interval=1000",
+		want: &timeFields{
+			Timeout:  90 * time.Minute,
+			Delays:   []time.Duration{time.Second, 250 * time.Millisecond},
+			Interval: durationp(1000),
+		},
+	}, {
+		data: "week=2019-W53",
+		err:  &ValueError{Key: "week", Value: "2019-W53", Field: "Week", Type: timeType, Err: errInvalidWeek},
+	}, {
+		data: "timeout=soon",
+		err: &ValueError{Key: "timeout", Value: "soon", Field: "Timeout", Type: durationType,
+			Err: mustErr(time.ParseDuration("soon"))},
+	}}
+
+	for i, tt := range tests {
+		dst := new(timeFields)
+		err := NewDecoder(strings.NewReader(tt.data)).Decode(dst)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("#%d: got error %v, want %v", i, err, tt.err)
+		} else if err == nil && !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("#%d: got %+v, want %+v", i, dst, tt.want)
+		}
+	}
+}
+
+func TestDecoderTimeLayoutError(t *testing.T) {
+	dst := new(timeFields)
+	err := NewDecoder(strings.NewReader("date=04.03.2019")).Decode(dst)
+	verr, ok := err.(*ValueError)
+	if !ok {
+		t.Fatalf("got error %v, want *ValueError", err)
+	}
+	if _, ok := verr.Err.(*time.ParseError); !ok || verr.Field != "Date" {
+		t.Errorf("got %#v, want a *time.ParseError for field Date", verr)
+	}
+}
+
+func TestDecoderWithLocation(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	dst := new(timeFields)
+	d := NewDecoder(strings.NewReader("at=2019-03-04T05:06:07Z&date=2019-03-04")).WithLocation(loc)
+	if err := d.Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2019, 3, 4, 0, 0, 0, 0, loc); !dst.Date.Equal(want) || dst.Date.Location() != loc {
+		t.Errorf("got date %v, want %v", dst.Date, want)
+	}
+	if want := date(2019, 3, 4, 5, 6, 7); !dst.At.Equal(want) {
+		t.Errorf("got at %v, want %v", dst.At, want)
+	}
+}
+
+func TestEncoderTime(t *testing.T) {
+	src := &timeFields{
+		At:       time.Date(2019, 3, 4, 5, 6, 7, 8, time.UTC),
+		Date:     date(2019, 3, 4, 0, 0, 0),
+		Local:    timep(date(2019, 3, 4, 5, 6, 0)),
+		Month:    date(2019, 3, 1, 0, 0, 0),
+		Week:     date(2021, 1, 3, 0, 0, 0),
+		Clock:    date(0, 1, 1, 5, 6, 7),
+		Custom:   date(2019, 3, 4, 0, 0, 0),
+		Days:     []time.Time{date(2019, 3, 4, 0, 0, 0), date(2019, 3, 5, 0, 0, 0)},
+		Timeout:  90 * time.Minute,
+		Delays:   []time.Duration{time.Second},
+		Interval: nil,
+	}
+	want := url.Values{
+		"at":      {"2019-03-04T05:06:07.000000008Z"},
+		"date":    {"2019-03-04"},
+		"local":   {"2019-03-04T05:06"},
+		"month":   {"2019-03"},
+		"week":    {"2020-W53"},
+		"clock":   {"05:06"},
+		"custom":  {"04/03/2019"},
+		"days":    {"2019-03-04", "2019-03-05"},
+		"timeout": {"1h30m0s"},
+		"delays":  {"1s"},
+	}
+
+	var b strings.Builder
+	if err := NewEncoder(&b).Encode(src); err != nil {
+		t.Fatal(err)
+	}
+	got, err := url.ParseQuery(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The encoded values, apart from the truncated ones, decode back into src.
+	dst := new(timeFields)
+	if err := NewDecoder(strings.NewReader(b.String())).Decode(dst); err != nil {
+		t.Fatal(err)
+	}
+	if !dst.At.Equal(src.At) || !dst.Date.Equal(src.Date) || dst.Timeout != src.Timeout ||
+		!reflect.DeepEqual(dst.Days, src.Days) {
+		t.Errorf("got %+v, want %+v", dst, src)
+	}
+}
+
+func timep(t time.Time) *time.Time { return &t }
+
+func durationp(d time.Duration) *time.Duration { return &d }
+
+func mustErr(_ interface{}, err error) error { return err }
@@ -0,0 +1,261 @@
+package form
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A ValidationError describes a decoded value that violates one
+// of the validation rules specified in its struct field's tag.
+type ValidationError struct {
+	// The key of the value, e.g. "user.email".
+	Key string
+	// The Go path of the struct field, e.g. "User.Email".
+	Field string
+	// The name of the violated rule, e.g. "minlen".
+	Rule string
+	// The rule's parameter, e.g. "8", or empty if the rule takes none.
+	Param string
+	// The offending value, or empty if the rule is "required"
+	// or if the value is a slice or a map.
+	Value string
+}
+
+func (err *ValidationError) Error() string {
+	if err.Rule == "required" {
+		return fmt.Sprintf("form: %q value is required", err.Key)
+	}
+	if err.Param == "" {
+		return fmt.Sprintf("form: %q value %q is not a valid %s", err.Key, err.Value, err.Rule)
+	}
+	return fmt.Sprintf("form: %q value %q violates the %s=%s rule", err.Key, err.Value, err.Rule, err.Param)
+}
+
+// EnableValidation causes the Decoder to validate the decoded values
+// against the rules specified in their struct fields' tags as options,
+// e.g. `form:"name,required,minlen=2,maxlen=64"`. The supported rules are:
+//
+//	required   the input must contain a non-empty value for the field
+//	min=n      a number must not be less than n
+//	max=n      a number must not be greater than n
+//	minlen=n   a string must have at least n characters, a slice or map at least n elements
+//	maxlen=n   a string must have at most n characters, a slice or map at most n elements
+//	oneof=a|b  the value must be one of the "|" separated values
+//	email      a string must be an email address
+//	url        a string must be an absolute URL
+//	pattern=re a string must match the regular expression, which
+//	           must be the tag's last option since it may contain commas
+//
+// Except for minlen and maxlen, the rules apply to each element of a slice.
+// A field whose key is absent from the input is validated only against the
+// required rule, which a field decoded from its default value satisfies.
+// The required fields of nested structs are validated even if none of the
+// struct's keys is present, a nil pointer to such a struct is allocated.
+// Every violation is reported as a ValidationError and they are all
+// returned as DecodeErrors, along with all of the ValueErrors found by
+// the Decoder, as if it collected errors.
+func (d *Decoder) EnableValidation() *Decoder {
+	d.validate = true
+	return d
+}
+
+// validateField validates the value fv, decoded from the given key,
+// against the rules specified in opts. A value decoded from the field's
+// default satisfies the required rule.
+func (d *Decoder) validateField(fv reflect.Value, key, path string, opts tagOptions, defaulted bool) error {
+	present := d.isPresent(key)
+	for _, opt := range opts.list() {
+		name, param := opt, ""
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			name, param = opt[:i], opt[i+1:]
+		}
+		if _, ok := validators[name]; !ok && name != "required" {
+			continue
+		}
+
+		if name == "required" {
+			if !present && !defaulted {
+				d.errs = append(d.errs, &ValidationError{Key: key, Field: path, Rule: name})
+			}
+			continue
+		}
+		if !present {
+			continue
+		}
+
+		v := fv
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				break
+			}
+			v = v.Elem()
+		}
+
+		// Rules other than minlen and maxlen apply to slice elements.
+		vals := []reflect.Value{v}
+		if v.Kind() == reflect.Slice && name != "minlen" && name != "maxlen" {
+			vals = make([]reflect.Value, v.Len())
+			for i := range vals {
+				vals[i] = v.Index(i)
+			}
+		}
+		for _, v := range vals {
+			ok, err := validators[name](v, param)
+			if err != nil {
+				return fmt.Errorf("form: invalid %s rule on field %s: %v", name, path, err)
+			}
+			if !ok {
+				d.errs = append(d.errs, &ValidationError{Key: key, Field: path, Rule: name, Param: param, Value: encodeString(v)})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// hasValueErrors reports whether errs contains errors other than
+// ValidationErrors, i.e. errors caused by values that could not be decoded.
+func hasValueErrors(errs []error) bool {
+	for _, err := range errs {
+		if _, ok := err.(*ValidationError); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isPresent reports whether the input contains a non-empty
+// value for the given key, or any value nested inside the key.
+func (d *Decoder) isPresent(key string) bool {
+	for _, v := range d.values(key) {
+		if v != "" {
+			return true
+		}
+	}
+	return len(d.files[key]) > 0 || d.hasPrefix(key)
+}
+
+// validators maps the names of the rules to the functions that implement
+// them. A validator function reports whether the value v satisfies the rule
+// with the given param, it returns an error if the param is invalid.
+var validators = map[string]func(v reflect.Value, param string) (bool, error){
+	"min":     func(v reflect.Value, p string) (bool, error) { return compareNumber(v, p, 1) },
+	"max":     func(v reflect.Value, p string) (bool, error) { return compareNumber(v, p, -1) },
+	"minlen":  func(v reflect.Value, p string) (bool, error) { return compareLength(v, p, 1) },
+	"maxlen":  func(v reflect.Value, p string) (bool, error) { return compareLength(v, p, -1) },
+	"oneof":   validateOneOf,
+	"email":   validateEmail,
+	"url":     validateURL,
+	"pattern": validatePattern,
+}
+
+// compareNumber reports whether the number v compared to the number in p
+// has the given sign, or is equal to it. Non-numeric values are ignored.
+func compareNumber(v reflect.Value, p string, sign int) (bool, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		return cmpSign(v.Int() < n, v.Int() > n) != -sign, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		return cmpSign(v.Uint() < n, v.Uint() > n) != -sign, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return false, err
+		}
+		return cmpSign(v.Float() < n, v.Float() > n) != -sign, nil
+	}
+	return true, nil
+}
+
+// compareLength reports whether the length of v compared to the number
+// in p has the given sign, or is equal to it. The length of a string is
+// the number of its characters. Values without a length are ignored.
+func compareLength(v reflect.Value, p string, sign int) (bool, error) {
+	n, err := strconv.Atoi(p)
+	if err != nil {
+		return false, err
+	}
+	var ln int
+	switch v.Kind() {
+	case reflect.String:
+		ln = utf8.RuneCountInString(v.String())
+	case reflect.Slice, reflect.Map, reflect.Array:
+		ln = v.Len()
+	default:
+		return true, nil
+	}
+	return cmpSign(ln < n, ln > n) != -sign, nil
+}
+
+func cmpSign(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// validateOneOf reports whether the string representation
+// of v is one of the "|" separated values in p.
+func validateOneOf(v reflect.Value, p string) (bool, error) {
+	s := encodeString(v)
+	for _, o := range strings.Split(p, "|") {
+		if s == o {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateEmail reports whether the string v is an email address.
+func validateEmail(v reflect.Value, p string) (bool, error) {
+	if v.Kind() != reflect.String {
+		return true, nil
+	}
+	addr, err := mail.ParseAddress(v.String())
+	return err == nil && addr.Address == v.String(), nil
+}
+
+// validateURL reports whether the string v is an absolute URL.
+func validateURL(v reflect.Value, p string) (bool, error) {
+	if v.Kind() != reflect.String {
+		return true, nil
+	}
+	u, err := url.Parse(v.String())
+	return err == nil && u.Scheme != "" && u.Host != "", nil
+}
+
+// patterns caches the compiled regular expressions of the pattern rules.
+var patterns sync.Map // map[string]*regexp.Regexp
+
+// validatePattern reports whether the string v matches the regular expression p.
+func validatePattern(v reflect.Value, p string) (bool, error) {
+	if v.Kind() != reflect.String {
+		return true, nil
+	}
+	re, ok := patterns.Load(p)
+	if !ok {
+		r, err := regexp.Compile(p)
+		if err != nil {
+			return false, err
+		}
+		re, _ = patterns.LoadOrStore(p, r)
+	}
+	return re.(*regexp.Regexp).MatchString(v.String()), nil
+}
@@ -0,0 +1,141 @@
+package form
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type signupType struct {
+	Name    string   `form:"name,required,minlen=2,maxlen=8"`
+	Email   string   `form:"email,required,email"`
+	Website string   `form:"website,url"`
+	Age     int      `form:"age,min=18,max=130"`
+	Score   *float64 `form:"score,min=0.5"`
+	Plan    string   `form:"plan,oneof=free|pro"`
+	Tags    []string `form:"tags,minlen=1,maxlen=2,pattern=^[a-z]{2,4}$"`
+	Codes   []uint   `form:"codes,max=99"`
+	Address struct {
+		City string `form:"city,required"`
+	} `form:"address,required"`
+	Optional string `form:"optional,minlen=100"`
+}
+
+func TestDecoderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		collect bool
+		err     error
+	}{{
+		name: "valid input",
+		data: "name=joe&email=joe@example.com&website=https://example.com&age=30&score=0.5" +
+			"&plan=pro&tags=ab&tags=abcd&codes=1&codes=99&address.city=x",
+	}, {
+		name: "violations",
+		data: "name=j&email=Joe+<joe@example.com>&website=example.com&age=17&score=0.1" +
+			"&plan=gold&tags=a&tags=ab&tags=abcde&codes=100&address.town=x",
+		err: DecodeErrors{
+			&ValidationError{Key: "name", Field: "Name", Rule: "minlen", Param: "2", Value: "j"},
+			&ValidationError{Key: "email", Field: "Email", Rule: "email", Value: "Joe <joe@example.com>"},
+			&ValidationError{Key: "website", Field: "Website", Rule: "url", Value: "example.com"},
+			&ValidationError{Key: "age", Field: "Age", Rule: "min", Param: "18", Value: "17"},
+			&ValidationError{Key: "score", Field: "Score", Rule: "min", Param: "0.5", Value: "0.1"},
+			&ValidationError{Key: "plan", Field: "Plan", Rule: "oneof", Param: "free|pro", Value: "gold"},
+			&ValidationError{Key: "tags", Field: "Tags", Rule: "maxlen", Param: "2"},
+			&ValidationError{Key: "tags", Field: "Tags", Rule: "pattern", Param: "^[a-z]{2,4}$", Value: "a"},
+			&ValidationError{Key: "codes", Field: "Codes", Rule: "max", Param: "99", Value: "100"},
+			&ValidationError{Key: "address.city", Field: "Address.City", Rule: "required"},
+		},
+	}, {
+		name: "missing required values",
+		data: "name=&age=200",
+		err: DecodeErrors{
+			&ValidationError{Key: "name", Field: "Name", Rule: "required"},
+			&ValidationError{Key: "email", Field: "Email", Rule: "required"},
+			&ValidationError{Key: "age", Field: "Age", Rule: "max", Param: "130", Value: "200"},
+			&ValidationError{Key: "address.city", Field: "Address.City", Rule: "required"},
+			&ValidationError{Key: "address", Field: "Address", Rule: "required"},
+		},
+	}, {
+		name: "violations before value errors",
+		data: "name=&email=x&age=abc&address.city=x",
+		err: DecodeErrors{
+			&ValidationError{Key: "name", Field: "Name", Rule: "required"},
+			&ValidationError{Key: "email", Field: "Email", Rule: "email", Value: "x"},
+			&ValueError{Key: "age", Value: "abc", Field: "Age", Type: reflect.TypeOf(0), Err: numError("ParseInt", "abc", strconv.ErrSyntax)},
+		},
+	}, {
+		name: "value errors before violations",
+		data: "name=joe&email=joe@example.com&score=x",
+		err: DecodeErrors{
+			&ValueError{Key: "score", Value: "x", Field: "Score", Type: reflect.TypeOf(new(float64)), Err: numError("ParseFloat", "x", strconv.ErrSyntax)},
+			&ValidationError{Key: "address.city", Field: "Address.City", Rule: "required"},
+			&ValidationError{Key: "address", Field: "Address", Rule: "required"},
+		},
+	}, {
+		name:    "violations alongside value errors",
+		data:    "name=joe&email=x&age=abc&address.city=x",
+		collect: true,
+		err: DecodeErrors{
+			&ValidationError{Key: "email", Field: "Email", Rule: "email", Value: "x"},
+			&ValueError{Key: "age", Value: "abc", Field: "Age", Type: reflect.TypeOf(0), Err: numError("ParseInt", "abc", strconv.ErrSyntax)},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data)).EnableValidation()
+			if tt.collect {
+				d.CollectErrors()
+			}
+			if err := d.Decode(new(signupType)); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+type pagingType struct {
+	Name   string `form:"name"`
+	Paging struct {
+		Sort string `form:"sort,required"`
+	} `form:"paging"`
+	Filter *struct {
+		Brand string `form:"brand,required"`
+	} `form:"filter"`
+	Size int         `form:"size,default=10,required"`
+	Next *pagingType `form:"next"`
+}
+
+func TestDecoderValidationNested(t *testing.T) {
+	dst := new(pagingType)
+	err := NewDecoder(strings.NewReader("name=x")).EnableValidation().Decode(dst)
+	want := DecodeErrors{
+		&ValidationError{Key: "paging.sort", Field: "Paging.Sort", Rule: "required"},
+		&ValidationError{Key: "filter.brand", Field: "Filter.Brand", Rule: "required"},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	if dst.Size != 10 || dst.Next != nil {
+		t.Errorf("got %+v, want Size 10 and no Next", dst)
+	}
+}
+
+func TestDecoderValidationInvalidRule(t *testing.T) {
+	dst := new(struct {
+		Age int `form:"age,min=abc"`
+	})
+	err := NewDecoder(strings.NewReader("age=1")).EnableValidation().Decode(dst)
+	if err == nil || !strings.Contains(err.Error(), "invalid min rule") {
+		t.Errorf("got error %v, want invalid min rule error", err)
+	}
+}
+
+func TestDecoderWithoutValidation(t *testing.T) {
+	if err := Unmarshal([]byte("name=j&age=17"), new(signupType)); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}